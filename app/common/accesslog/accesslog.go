@@ -0,0 +1,124 @@
+package accesslog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/config"
+)
+
+// Record is a line of the access log. It holds the analytics event of a request after the privacy settings of the
+// tenant are applied, so that no data the tenant opted out of is written to disk, and replays send what the live
+// report did. A request the tenant must not track has no record at all.
+type Record struct {
+	analytics.Event
+	DoNotTrack bool `json:"doNotTrack,omitempty"` // The client asked not to be tracked, kept for tenants honoring it later
+}
+
+// maxLineSize bounds the size of a record when reading an access log.
+const maxLineSize = 1 << 20
+
+var (
+	writer *Writer
+	mutex  sync.RWMutex
+)
+
+// Init opens the access log. It does nothing if no path is configured.
+func Init(conf config.AccessLog) {
+	if conf.Path == "" {
+		hlog.Infof("[AccessLog] No path configured, access log disabled")
+		return
+	}
+	w, err := NewWriter(conf.Path, conf.MaxBytes, time.Duration(conf.RotateIntervalHours)*time.Hour, conf.Compress)
+	if err != nil {
+		hlog.Fatalf("[AccessLog] Error opening %s: %v", conf.Path, err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	writer = w
+	hlog.Infof("[AccessLog] Writing access log to %s", conf.Path)
+}
+
+// Log appends a record to the access log, if enabled.
+func Log(ctx context.Context, record Record) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if writer == nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[AccessLog] Error marshaling record: %v", err)
+		return
+	}
+	if _, err := writer.Write(append(line, '\n')); err != nil {
+		hlog.CtxErrorf(ctx, "[AccessLog] Error writing record: %v", err)
+	}
+}
+
+// Shutdown closes the access log.
+func Shutdown(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if writer == nil {
+		return
+	}
+	if err := writer.Close(); err != nil {
+		hlog.CtxErrorf(ctx, "[AccessLog] Error closing access log: %v", err)
+	}
+	writer = nil
+}
+
+// ReadFile calls fn for every record of an access log file, decompressing it if its name ends with .gz.
+// Reading stops at the first error, which is returned with its line number.
+func ReadFile(path string, fn func(Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close() // Ignore error
+	}(file)
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer func(zr *gzip.Reader) {
+			_ = zr.Close() // Ignore error
+		}(zr)
+		reader = zr
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err := fn(record); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
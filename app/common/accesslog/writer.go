@@ -0,0 +1,183 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// Writer appends to a file, rotating it once it reaches a size or an age.
+// Rotated files are renamed with the rotation time as a suffix, and optionally compressed with gzip in the background.
+type Writer struct {
+	path           string
+	maxBytes       int64
+	rotateInterval time.Duration
+	compress       bool
+
+	mutex        sync.Mutex
+	file         *os.File
+	size         int64
+	openedAt     time.Time
+	retryAt      time.Time // Rotation is not attempted before, after a failed one
+	compressions sync.WaitGroup
+}
+
+const (
+	// rotatedTimeFormat sorts rotated files in rotation order.
+	rotatedTimeFormat = "20060102-150405.000"
+	rotateRetryDelay  = time.Minute
+)
+
+// NewWriter opens the file at path for appending. A zero maxBytes or rotateInterval disables the matching rotation.
+func NewWriter(path string, maxBytes int64, rotateInterval time.Duration, compress bool) (*Writer, error) {
+	w := &Writer{
+		path:           path,
+		maxBytes:       maxBytes,
+		rotateInterval: rotateInterval,
+		compress:       compress,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p to the file, rotating it first if needed. p should be a whole line, so lines never straddle files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.shouldRotate(int64(len(p))) {
+		w.rotate()
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the file and waits for pending compressions.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mutex.Unlock()
+
+	w.compressions.Wait()
+	return err
+}
+
+func (w *Writer) shouldRotate(size int64) bool {
+	if time.Now().Before(w.retryAt) {
+		return false
+	}
+	if w.maxBytes > 0 && w.size+size > w.maxBytes {
+		return true
+	}
+	return w.rotateInterval > 0 && time.Since(w.openedAt) >= w.rotateInterval
+}
+
+// open opens the file for appending. Must be called with the mutex held.
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+	return nil
+}
+
+// rotate renames the current file and opens a new one. Must be called with the mutex held.
+// If either step fails, the error is logged and lines keep being appended to the current file, so that the access log
+// survives until rotation succeeds again, no sooner than rotateRetryDelay later.
+func (w *Writer) rotate() {
+	rotated := w.rotatedPath()
+	if err := os.Rename(w.path, rotated); err != nil {
+		hlog.Errorf("[AccessLog] Error rotating %s, appending to it: %v", w.path, err)
+		w.retryAt = time.Now().Add(rotateRetryDelay)
+		return
+	}
+	previous := w.file
+	if err := w.open(); err != nil {
+		hlog.Errorf("[AccessLog] Error opening %s after rotation, appending to %s: %v", w.path, rotated, err)
+		w.retryAt = time.Now().Add(rotateRetryDelay)
+		return
+	}
+	if err := previous.Close(); err != nil {
+		hlog.Errorf("[AccessLog] Error closing %s: %v", rotated, err)
+	}
+	if w.compress {
+		w.compressions.Add(1)
+		go func() {
+			defer w.compressions.Done()
+			if err := compressFile(rotated); err != nil {
+				hlog.Errorf("[AccessLog] Error compressing %s: %v", rotated, err)
+			}
+		}()
+	}
+}
+
+// rotatedPath returns an unused path for the current file once rotated, suffixed with a counter when rotations share
+// a timestamp, so that a quick succession of rotations never overwrites a rotated file.
+func (w *Writer) rotatedPath() string {
+	base := fmt.Sprintf("%s.%s", w.path, time.Now().UTC().Format(rotatedTimeFormat))
+	rotated := base
+	for i := 1; ; i++ {
+		_, errPlain := os.Lstat(rotated)
+		_, errCompressed := os.Lstat(rotated + ".gz")
+		if os.IsNotExist(errPlain) && os.IsNotExist(errCompressed) {
+			return rotated
+		}
+		rotated = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// compressFile replaces a file with its gzip-compressed version, suffixed with .gz.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(src *os.File) {
+		_ = src.Close() // Ignore error
+	}(src)
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,63 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.jsonl")
+	w, err := NewWriter(path, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q): %v", line, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "third\n" {
+		t.Errorf("current file = %q, want the last line only", data)
+	}
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Errorf("got rotated files %v, want 2", rotated)
+	}
+}
+
+func TestWriterKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.jsonl")
+	w, err := NewWriter(path, 10, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Renaming a removed file fails, as it would on a full or read-only file system
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"second\n", "third\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) after a failed rotation: %v", line, err)
+		}
+	}
+	if w.file == nil || !strings.HasSuffix(w.file.Name(), "access.jsonl") {
+		t.Errorf("writer lost its file after a failed rotation")
+	}
+	if w.retryAt.IsZero() {
+		t.Errorf("failed rotation was not delayed")
+	}
+}
@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/nats-io/nats.go"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+// Event represents a single analytics event, delivered to every sink configured for the tenant.
+type Event struct {
+	AppID      string    `json:"appId"`      // The tenant the event belongs to
+	SiteID     string    `json:"siteId"`     // The Matomo site ID of the tenant
+	ActionName string    `json:"actionName"` // The name of the action being tracked
+	URL        string    `json:"url"`        // The URL of the page/resource being accessed
+	UserAgent  string    `json:"userAgent"`  // The user's browser user agent
+	ClientIP   string    `json:"clientIp"`   // The user's IP address
+	ClientTime time.Time `json:"clientTime"` // The time of the event
+
+	Status           int            `json:"status,omitempty"`           // The HTTP status of the response
+	ResponseSize     int64          `json:"responseSize,omitempty"`     // The size of the response body in bytes
+	BytesUploaded    int64          `json:"bytesUploaded,omitempty"`    // The size of the request body in bytes
+	Duration         time.Duration  `json:"durationNs,omitempty"`       // The time taken to handle the request
+	RequestID        string         `json:"requestId,omitempty"`        // The request ID
+	Referer          string         `json:"referer,omitempty"`          // The referer of the request
+	AcceptLanguage   string         `json:"acceptLanguage,omitempty"`   // The Accept-Language header of the request
+	CustomDimensions map[int]string `json:"customDimensions,omitempty"` // Matomo custom dimension values by dimension ID
+	EventCategory    string         `json:"eventCategory,omitempty"`    // The event category
+	EventAction      string         `json:"eventAction,omitempty"`      // The event action
+	EventName        string         `json:"eventName,omitempty"`        // The event name
+	EventValue       float64        `json:"eventValue,omitempty"`       // The event value
+}
+
+// Sink receives analytics events.
+type Sink interface {
+	// Report delivers an event to the sink. It must not block the caller for long.
+	Report(ctx context.Context, event Event)
+	// Close flushes pending events and releases the resources of the sink.
+	Close(ctx context.Context)
+}
+
+const (
+	SinkTypeMatomo  = "matomo"
+	SinkTypeFile    = "file"
+	SinkTypeWebhook = "webhook"
+	SinkTypeNATS    = "nats"
+
+	// defaultSinkName is the name of the Matomo sink registered when no sinks are configured.
+	defaultSinkName = "matomo"
+)
+
+var (
+	sinks        map[string]Sink
+	defaultSinks []string
+	mutex        sync.RWMutex
+)
+
+// Init initializes the configured analytics sinks.
+// If no sinks are configured, a single Matomo sink is used for all tenants.
+// It should be called once after the Matomo client is initialized, typically during application startup.
+func Init(conf config.Analytics) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sinks = make(map[string]Sink)
+	if len(conf.Sinks) == 0 {
+		sinks[defaultSinkName] = newMatomoSink()
+		defaultSinks = []string{defaultSinkName}
+		hlog.Infof("[Analytics] No sinks configured, using Matomo only")
+		return
+	}
+	for name, sinkConf := range conf.Sinks {
+		sink, err := newSink(name, sinkConf)
+		if err != nil {
+			hlog.Fatalf("[Analytics] Error initializing sink %s: %v", name, err)
+		}
+		sinks[name] = sink
+		hlog.Infof("[Analytics] Initialized sink %s of type %s", name, sinkConf.Type)
+	}
+	defaultSinks = conf.DefaultSinks
+	for _, name := range defaultSinks {
+		if _, ok := sinks[name]; !ok {
+			hlog.Fatalf("[Analytics] Default sink %s is not configured", name)
+		}
+	}
+}
+
+// newSink creates a sink from its configuration.
+func newSink(name string, conf config.AnalyticsSink) (Sink, error) {
+	switch conf.Type {
+	case SinkTypeMatomo:
+		if conf.URL != "" {
+			return newDedicatedMatomoSink(name, conf.URL, conf.AuthToken), nil
+		}
+		return newMatomoSink(), nil
+	case SinkTypeFile:
+		return newFileSink(conf.Path)
+	case SinkTypeWebhook:
+		return newWebhookSink(conf.URL, conf.Headers, conf.BufferSize)
+	case SinkTypeNATS:
+		var opts []nats.Option
+		if conf.CredsFile != "" {
+			opts = append(opts, nats.UserCredentials(conf.CredsFile))
+		}
+		if conf.CAFile != "" {
+			opts = append(opts, nats.RootCAs(conf.CAFile))
+		}
+		return newNATSSink(conf.URL, conf.Subject, conf.BufferSize, opts...)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", conf.Type)
+	}
+}
+
+// Report fans out an event to the named sinks, or to the default sinks if no names are given.
+func Report(ctx context.Context, sinkNames []string, event Event) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if sinks == nil {
+		hlog.CtxErrorf(ctx, "[Analytics] Report called before Init")
+		return
+	}
+	if len(sinkNames) == 0 {
+		sinkNames = defaultSinks
+	}
+	for _, name := range sinkNames {
+		sink, ok := sinks[name]
+		if !ok {
+			hlog.CtxWarnf(ctx, "[Analytics] Unknown sink %s, dropping event", name)
+			continue
+		}
+		sink.Report(ctx, event)
+	}
+}
+
+// Shutdown closes all sinks, flushing their pending events.
+func Shutdown(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for name, sink := range sinks {
+		hlog.CtxInfof(ctx, "[Analytics] Closing sink %s", name)
+		sink.Close(ctx)
+	}
+	sinks = nil
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+)
+
+// fileSink appends events to a file as JSON lines.
+type fileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if path == "" {
+		return nil, errors.New("missing file path")
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Report(ctx context.Context, event Event) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[Analytics] Error marshaling event: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return
+	}
+	if _, err := s.file.Write(line); err != nil {
+		hlog.CtxErrorf(ctx, "[Analytics] Error writing event to %s: %v", s.file.Name(), err)
+	}
+}
+
+func (s *fileSink) Close(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Close(); err != nil {
+		hlog.CtxErrorf(ctx, "[Analytics] Error closing %s: %v", s.file.Name(), err)
+	}
+	s.file = nil
+}
@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/tundrawork/stargate/app/common/matomo"
+	"github.com/tundrawork/stargate/config"
+)
+
+// matomoSink delivers events to a Matomo client.
+// Without a client of its own, it uses the default Matomo client shared by the whole application.
+type matomoSink struct {
+	client *matomo.Client
+}
+
+func newMatomoSink() *matomoSink {
+	return &matomoSink{}
+}
+
+// newDedicatedMatomoSink creates a sink with its own Matomo client, for tenants tracked on another Matomo server.
+// The client is configured like the default one, with its spool in a subdirectory named after the sink, so that
+// its batches are never replayed to the other server.
+func newDedicatedMatomoSink(name, matomoURL, authToken string) *matomoSink {
+	conf := config.Conf.Matomo
+	if conf.SpoolDir != "" {
+		conf.SpoolDir = filepath.Join(conf.SpoolDir, name)
+	}
+	return &matomoSink{
+		client: matomo.NewClient(matomoURL, authToken, MatomoOptions(conf)...),
+	}
+}
+
+// MatomoOptions returns the options of a Matomo client from its configuration.
+func MatomoOptions(conf config.MatomoClient) []matomo.Option {
+	return []matomo.Option{
+		matomo.WithWorkers(conf.NumWorkers),
+		matomo.WithBatchSize(conf.BatchSize),
+		matomo.WithBatchMaxBytes(conf.BatchMaxBytes),
+		matomo.WithEventBufferSize(conf.EventBufferSize),
+		matomo.WithFlushInterval(time.Duration(conf.FlushIntervalMs) * time.Millisecond),
+		matomo.WithTimeout(time.Duration(conf.TimeoutMs) * time.Millisecond),
+		matomo.WithShutdownTimeout(time.Duration(conf.ShutdownTimeoutMs) * time.Millisecond),
+		matomo.WithBackpressurePolicy(
+			matomo.BackpressurePolicy(conf.Backpressure),
+			time.Duration(conf.BlockTimeoutMs)*time.Millisecond,
+			conf.SampleRate,
+		),
+		matomo.WithRetries(conf.MaxRetries),
+		matomo.WithSpool(conf.SpoolDir, conf.SpoolMaxBytes),
+	}
+}
+
+func (s *matomoSink) Report(ctx context.Context, event Event) {
+	matomoEvent := MatomoEvent(event)
+	if s.client == nil {
+		matomo.ReportEvent(ctx, matomoEvent)
+		return
+	}
+	if err := s.client.Report(ctx, matomoEvent); err != nil {
+		hlog.CtxWarnf(ctx, "[Analytics] Error reporting event to Matomo, dropping event: %v", err)
+	}
+}
+
+// MatomoEvent converts an event to the Matomo event tracking it.
+func MatomoEvent(event Event) matomo.Event {
+	return matomo.Event{
+		SiteID:     event.SiteID,
+		ActionName: event.ActionName,
+		URL:        event.URL,
+		UserAgent:  event.UserAgent,
+		ClientIP:   event.ClientIP,
+		ClientTime: event.ClientTime,
+
+		Status:           event.Status,
+		RequestID:        event.RequestID,
+		ResponseSize:     event.ResponseSize,
+		BytesUploaded:    event.BytesUploaded,
+		Duration:         event.Duration,
+		Referer:          event.Referer,
+		AcceptLanguage:   event.AcceptLanguage,
+		CustomDimensions: event.CustomDimensions,
+		EventCategory:    event.EventCategory,
+		EventAction:      event.EventAction,
+		EventName:        event.EventName,
+		EventValue:       event.EventValue,
+	}
+}
+
+// Close closes the client of the sink, if any.
+// The default Matomo client is shut down on its own as it may be shared by multiple sinks.
+func (s *matomoSink) Close(ctx context.Context) {
+	if s.client == nil {
+		return
+	}
+	if err := s.client.Close(ctx); err != nil {
+		hlog.CtxErrorf(ctx, "[Analytics] Error closing Matomo client: %v", err)
+	}
+}
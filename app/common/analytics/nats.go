@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+	"github.com/nats-io/nats.go"
+)
+
+// natsSink publishes every event as a JSON message to a NATS subject.
+// Events are queued and published by a background publisher. The connection is established in the background and
+// re-established after failures by the NATS client, which buffers the messages published in the meantime.
+type natsSink struct {
+	conn      *nats.Conn
+	subject   string
+	eventChan chan Event
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+const (
+	defaultNATSBufferSize = 1000
+	natsDialTimeout       = 3 * time.Second
+	natsReconnectDelay    = 5 * time.Second
+	natsFlushTimeout      = 3 * time.Second
+)
+
+// newNATSSink connects to the NATS servers at rawURL, a comma-separated list of URLs. User and password or a token
+// are taken from the URL, the "tls" scheme requires TLS. The options configure further authentication and TLS.
+func newNATSSink(rawURL, subject string, bufferSize int, opts ...nats.Option) (*natsSink, error) {
+	if rawURL == "" || subject == "" {
+		return nil, errors.New("missing nats url or subject")
+	}
+	if err := validateNATSSubject(subject); err != nil {
+		return nil, err
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultNATSBufferSize
+	}
+	opts = append([]nats.Option{
+		nats.Name("stargate"),
+		nats.Timeout(natsDialTimeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(natsReconnectDelay),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				hlog.Errorf("[Analytics] Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			hlog.Errorf("[Analytics] NATS error: %v", err)
+		}),
+	}, opts...)
+	conn, err := nats.Connect(rawURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := &natsSink{
+		conn:      conn,
+		subject:   subject,
+		eventChan: make(chan Event, bufferSize),
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+	go s.publisher()
+	return s, nil
+}
+
+// validateNATSSubject rejects subjects the server would refuse, and wildcards, which cannot be published to.
+func validateNATSSubject(subject string) error {
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid nats subject %q: contains whitespace", subject)
+	}
+	for _, token := range strings.Split(subject, ".") {
+		switch token {
+		case "":
+			return fmt.Errorf("invalid nats subject %q: empty token", subject)
+		case "*", ">":
+			return fmt.Errorf("invalid nats subject %q: wildcards cannot be published to", subject)
+		}
+	}
+	return nil
+}
+
+func (s *natsSink) Report(ctx context.Context, event Event) {
+	select {
+	case s.eventChan <- event:
+		// Event successfully queued
+	default:
+		hlog.CtxWarnf(ctx, "[Analytics] NATS buffer full, dropping event: %v", event)
+	}
+}
+
+func (s *natsSink) Close(ctx context.Context) {
+	close(s.stopChan)
+	select {
+	case <-s.doneChan:
+		// Events buffered while disconnected are lost
+		if s.conn.IsConnected() {
+			flushCtx, cancel := context.WithTimeout(ctx, natsFlushTimeout)
+			if err := s.conn.FlushWithContext(flushCtx); err != nil {
+				hlog.CtxErrorf(ctx, "[Analytics] Error flushing events to NATS: %v", err)
+			}
+			cancel()
+		}
+	case <-ctx.Done():
+		hlog.CtxErrorf(ctx, "[Analytics] NATS sink shutdown timed out")
+	}
+	s.conn.Close()
+}
+
+// publisher publishes queued events until the sink is closed, then publishes the events queued before.
+func (s *natsSink) publisher() {
+	defer close(s.doneChan)
+
+	for {
+		select {
+		case event := <-s.eventChan:
+			s.publish(event)
+		case <-s.stopChan:
+			for len(s.eventChan) > 0 {
+				s.publish(<-s.eventChan)
+			}
+			return
+		}
+	}
+}
+
+// publish publishes an event. While the server is unreachable, events are buffered by the NATS client until its
+// reconnect buffer is full, and dropped afterwards.
+func (s *natsSink) publish(event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		hlog.Errorf("[Analytics] Error marshaling event: %v", err)
+		return
+	}
+	if err := s.conn.Publish(s.subject, payload); err != nil {
+		hlog.Errorf("[Analytics] Error publishing to NATS, dropping event: %v", err)
+	}
+}
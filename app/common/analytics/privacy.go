@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"net"
+	"strings"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+// ApplyPrivacy applies privacy settings to an event before it is reported.
+// It returns false if the event must not be reported at all, either because the client asked not to be tracked
+// or because the object path is opted out.
+func ApplyPrivacy(event Event, privacy config.AnalyticsPrivacy, objectPath string, doNotTrack bool) (Event, bool) {
+	if privacy.HonorDoNotTrack && doNotTrack {
+		return Event{}, false
+	}
+	for _, optOutPath := range privacy.OptOutPaths {
+		// Paths ending with a slash opt out the whole directory, other paths opt out a single object.
+		if objectPath == optOutPath || (strings.HasSuffix(optOutPath, "/") && strings.HasPrefix(objectPath, optOutPath)) {
+			return Event{}, false
+		}
+	}
+	event.ClientIP = AnonymizeIP(event.ClientIP, privacy.IPv4Prefix, privacy.IPv6Prefix)
+	if privacy.StripUserAgent {
+		event.UserAgent = ""
+	}
+	return event, true
+}
+
+// DoNotTrack reports whether the DNT or Sec-GPC header values of a request ask not to track the client.
+func DoNotTrack(dnt, gpc string) bool {
+	return strings.TrimSpace(dnt) == "1" || strings.TrimSpace(gpc) == "1"
+}
+
+// AnonymizeIP keeps the leading prefix bits of an IP address and zeroes the rest.
+// A prefix of 0 or less keeps the address unchanged, as does an address that cannot be parsed.
+func AnonymizeIP(ip string, ipv4Prefix, ipv6Prefix int) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		if ipv4Prefix <= 0 || ipv4Prefix >= 32 {
+			return ip
+		}
+		return ipv4.Mask(net.CIDRMask(ipv4Prefix, 32)).String()
+	}
+	if ipv6Prefix <= 0 || ipv6Prefix >= 128 {
+		return ip
+	}
+	return parsed.Mask(net.CIDRMask(ipv6Prefix, 128)).String()
+}
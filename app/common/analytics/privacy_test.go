@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip                     string
+		ipv4Prefix, ipv6Prefix int
+		want                   string
+	}{
+		{"192.0.2.123", 24, 48, "192.0.2.0"},
+		{"192.0.2.123", 16, 48, "192.0.0.0"},
+		{"192.0.2.123", 0, 48, "192.0.2.123"},
+		{"192.0.2.123", 32, 48, "192.0.2.123"},
+		{"::ffff:192.0.2.123", 24, 48, "192.0.2.0"}, // IPv4-mapped addresses are masked as IPv4
+		{"2001:db8:1234:5678::1", 24, 48, "2001:db8:1234::"},
+		{"2001:db8:1234:5678::1", 24, 64, "2001:db8:1234:5678::"},
+		{"2001:db8:1234:5678::1", 24, 0, "2001:db8:1234:5678::1"},
+		{"2001:db8:1234:5678::1", 24, 128, "2001:db8:1234:5678::1"},
+		{"not an ip", 24, 48, "not an ip"},
+		{"", 24, 48, ""},
+	} {
+		if got := AnonymizeIP(tc.ip, tc.ipv4Prefix, tc.ipv6Prefix); got != tc.want {
+			t.Errorf("AnonymizeIP(%q, %d, %d) = %q, want %q", tc.ip, tc.ipv4Prefix, tc.ipv6Prefix, got, tc.want)
+		}
+	}
+}
+
+func TestDoNotTrack(t *testing.T) {
+	for _, tc := range []struct {
+		dnt, gpc string
+		want     bool
+	}{
+		{"", "", false},
+		{"1", "", true},
+		{"", "1", true},
+		{" 1 ", "", true},
+		{"0", "0", false},
+		{"0", "1", true},
+		{"yes", "true", false},
+	} {
+		if got := DoNotTrack(tc.dnt, tc.gpc); got != tc.want {
+			t.Errorf("DoNotTrack(%q, %q) = %v, want %v", tc.dnt, tc.gpc, got, tc.want)
+		}
+	}
+}
+
+func TestApplyPrivacy(t *testing.T) {
+	event := testEvent
+	event.ClientIP = "2001:db8:1234:5678::1"
+	event.UserAgent = "Mozilla/5.0"
+
+	for name, tc := range map[string]struct {
+		privacy    config.AnalyticsPrivacy
+		objectPath string
+		doNotTrack bool
+		tracked    bool
+		clientIP   string
+		userAgent  string
+	}{
+		"no privacy settings": {
+			objectPath: "/a.png", tracked: true, clientIP: "2001:db8:1234:5678::1", userAgent: "Mozilla/5.0",
+		},
+		"IP masked": {
+			privacy:    config.AnalyticsPrivacy{IPv4Prefix: 24, IPv6Prefix: 48},
+			objectPath: "/a.png", tracked: true, clientIP: "2001:db8:1234::", userAgent: "Mozilla/5.0",
+		},
+		"user agent stripped": {
+			privacy:    config.AnalyticsPrivacy{StripUserAgent: true},
+			objectPath: "/a.png", tracked: true, clientIP: "2001:db8:1234:5678::1",
+		},
+		"do not track honored": {
+			privacy:    config.AnalyticsPrivacy{HonorDoNotTrack: true},
+			objectPath: "/a.png", doNotTrack: true,
+		},
+		"do not track ignored": {
+			objectPath: "/a.png", doNotTrack: true, tracked: true, clientIP: "2001:db8:1234:5678::1", userAgent: "Mozilla/5.0",
+		},
+		"object opted out": {
+			privacy:    config.AnalyticsPrivacy{OptOutPaths: []string{"/a.png"}},
+			objectPath: "/a.png",
+		},
+		"object not opted out": {
+			privacy:    config.AnalyticsPrivacy{OptOutPaths: []string{"/a.png"}},
+			objectPath: "/a.png.bak", tracked: true, clientIP: "2001:db8:1234:5678::1", userAgent: "Mozilla/5.0",
+		},
+		"directory opted out": {
+			privacy:    config.AnalyticsPrivacy{OptOutPaths: []string{"/private/"}},
+			objectPath: "/private/docs/a.png",
+		},
+		"sibling of directory opted out": {
+			privacy:    config.AnalyticsPrivacy{OptOutPaths: []string{"/private/"}},
+			objectPath: "/private.png", tracked: true, clientIP: "2001:db8:1234:5678::1", userAgent: "Mozilla/5.0",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, tracked := ApplyPrivacy(event, tc.privacy, tc.objectPath, tc.doNotTrack)
+			if tracked != tc.tracked {
+				t.Fatalf("tracked = %v, want %v", tracked, tc.tracked)
+			}
+			if !tracked {
+				if !reflect.DeepEqual(got, Event{}) {
+					t.Errorf("untracked event = %+v, want the zero event", got)
+				}
+				return
+			}
+			if got.ClientIP != tc.clientIP || got.UserAgent != tc.userAgent {
+				t.Errorf("client IP %q and user agent %q, want %q and %q", got.ClientIP, got.UserAgent, tc.clientIP, tc.userAgent)
+			}
+			if got.URL != event.URL || got.RequestID != event.RequestID {
+				t.Errorf("event = %+v, want the other fields kept", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	AppID:      "app-a",
+	SiteID:     "1",
+	ActionName: "railgun_cdn:server:GetURL",
+	URL:        "https://cdn.example.com/a.png",
+	ClientIP:   "192.0.2.1",
+	Status:     200,
+	RequestID:  "req-1",
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := newFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Report(context.Background(), testEvent)
+	sink.Report(context.Background(), testEvent)
+	sink.Close(context.Background())
+	sink.Report(context.Background(), testEvent) // Ignored once closed
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), data)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.AppID != testEvent.AppID || event.Status != testEvent.Status || event.RequestID != testEvent.RequestID {
+		t.Errorf("event = %+v, want %+v", event, testEvent)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	batches := make(chan []Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %q, want the configured header", r.Header.Get("Authorization"))
+		}
+		var events []Event
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			t.Errorf("error decoding batch: %v", err)
+		}
+		batches <- events
+	}))
+	defer server.Close()
+
+	sink, err := newWebhookSink(server.URL, map[string]string{"Authorization": "Bearer token"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		sink.Report(context.Background(), testEvent)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sink.Close(ctx) // Flushes the queued events
+
+	var received []Event
+	for len(batches) > 0 {
+		received = append(received, <-batches...)
+	}
+	if len(received) != 3 {
+		t.Fatalf("got %d events, want 3", len(received))
+	}
+	if received[0].URL != testEvent.URL {
+		t.Errorf("event = %+v, want %+v", received[0], testEvent)
+	}
+}
+
+func TestWebhookSinkDropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // Hold the worker so that the buffer fills up
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink, err := newWebhookSink(server.URL, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		sink.Report(context.Background(), testEvent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Report blocked for %v with a full buffer", elapsed)
+	}
+}
+
+// natsStandIn accepts a single NATS client connection and returns its CONNECT options and the payloads it publishes.
+func natsStandIn(t *testing.T) (address string, connected <-chan string, published <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	connects := make(chan string, 1)
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = fmt.Fprint(conn, "INFO {\"max_payload\":1048576}\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "CONNECT "):
+				connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT "))
+			case strings.HasPrefix(line, "PING"):
+				_, _ = fmt.Fprint(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "PUB "):
+				payload, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				messages <- strings.Fields(line)[1] + " " + strings.TrimSpace(payload)
+			}
+		}
+	}()
+	return listener.Addr().String(), connects, messages
+}
+
+func TestNATSSink(t *testing.T) {
+	address, connected, published := natsStandIn(t)
+	sink, err := newNATSSink("nats://stargate:secret@"+address, "stargate.events", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close(context.Background())
+
+	select {
+	case options := <-connected:
+		var connect struct {
+			User     string `json:"user"`
+			Password string `json:"pass"`
+		}
+		if err := json.Unmarshal([]byte(options), &connect); err != nil {
+			t.Fatal(err)
+		}
+		if connect.User != "stargate" || connect.Password != "secret" {
+			t.Errorf("connected with %s, want the credentials of the URL", options)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sink did not connect")
+	}
+
+	sink.Report(context.Background(), testEvent)
+	select {
+	case message := <-published:
+		subject, payload, _ := strings.Cut(message, " ")
+		if subject != "stargate.events" {
+			t.Errorf("subject = %q, want stargate.events", subject)
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.AppID != testEvent.AppID {
+			t.Errorf("event = %+v, want %+v", event, testEvent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not published")
+	}
+}
+
+func TestNATSSinkDoesNotBlockWhenUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close() // Nothing listens there anymore
+
+	sink, err := newNATSSink(address, "stargate.events", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close(context.Background())
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		sink.Report(context.Background(), testEvent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Report blocked for %v while NATS is unreachable", elapsed)
+	}
+}
+
+func TestNATSSinkRejectsInvalidSubjects(t *testing.T) {
+	for _, subject := range []string{"stargate events", "stargate.events\r\nPUB x 1", "stargate..events", "stargate.*", "stargate.>"} {
+		if sink, err := newNATSSink("nats://127.0.0.1:4222", subject, 0); err == nil {
+			sink.Close(context.Background())
+			t.Errorf("subject %q accepted", subject)
+		}
+	}
+}
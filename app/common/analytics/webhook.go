@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+)
+
+// webhookSink posts batches of events as a JSON array to an HTTP endpoint.
+type webhookSink struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+	eventChan  chan Event
+	stopChan   chan struct{}
+	doneChan   chan struct{}
+}
+
+const (
+	defaultWebhookBufferSize = 1000
+	webhookBatchSize         = 100
+	webhookFlushInterval     = 1 * time.Second
+)
+
+func newWebhookSink(url string, headers map[string]string, bufferSize int) (*webhookSink, error) {
+	if url == "" {
+		return nil, errors.New("missing webhook url")
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultWebhookBufferSize
+	}
+	s := &webhookSink{
+		url:     url,
+		headers: headers,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		eventChan: make(chan Event, bufferSize),
+		stopChan:  make(chan struct{}),
+		doneChan:  make(chan struct{}),
+	}
+	go s.worker()
+	return s, nil
+}
+
+func (s *webhookSink) Report(ctx context.Context, event Event) {
+	select {
+	case s.eventChan <- event:
+		// Event successfully queued
+	default:
+		hlog.CtxWarnf(ctx, "[Analytics] Webhook buffer full, dropping event: %v", event)
+	}
+}
+
+func (s *webhookSink) Close(ctx context.Context) {
+	close(s.stopChan)
+	select {
+	case <-s.doneChan:
+	case <-ctx.Done():
+		hlog.CtxErrorf(ctx, "[Analytics] Webhook sink shutdown timed out")
+	}
+}
+
+// worker sends queued events in batches, when a batch is full or on every flush interval.
+func (s *webhookSink) worker() {
+	defer close(s.doneChan)
+
+	ticker := time.NewTicker(webhookFlushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case event := <-s.eventChan:
+			batch = append(batch, event)
+			if len(batch) >= webhookBatchSize {
+				s.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.send(batch)
+				batch = nil
+			}
+		case <-s.stopChan:
+			// Drain the events queued before stopping
+			for len(s.eventChan) > 0 {
+				batch = append(batch, <-s.eventChan)
+			}
+			if len(batch) > 0 {
+				s.send(batch)
+			}
+			return
+		}
+	}
+}
+
+func (s *webhookSink) send(events []Event) {
+	payload, err := json.Marshal(events)
+	if err != nil {
+		hlog.Errorf("[Analytics] Error marshaling webhook payload: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		hlog.Errorf("[Analytics] Error creating webhook request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		hlog.Errorf("[Analytics] Error sending %d events to webhook: %v", len(events), err)
+		return
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close() // Ignore error
+	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		hlog.Errorf("[Analytics] Webhook returned non-OK status: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,132 @@
+// Package apierror defines the errors returned by the API, each with a stable machine-readable code, the HTTP status
+// it is served with and whether the client may retry the request.
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+)
+
+// Code identifies the kind of error, it is part of the API and must not change once released.
+type Code string
+
+const (
+	CodeInvalidRequest     Code = "INVALID_REQUEST"     // The request is malformed or fails validation
+	CodeUnauthorized       Code = "UNAUTHORIZED"        // The tenant could not be authenticated
+	CodeNotFound           Code = "NOT_FOUND"           // The object or API path does not exist
+	CodeConflict           Code = "CONFLICT"            // The request conflicts with the current state of the object
+	CodePreconditionFailed Code = "PRECONDITION_FAILED" // A conditional header of the request did not match
+	CodeChecksumMismatch   Code = "CHECKSUM_MISMATCH"   // The uploaded object does not match its expected checksums
+	CodeTooLarge           Code = "TOO_LARGE"           // The request body is too large
+	CodeRevoked            Code = "REVOKED"             // The signed link has been revoked
+	CodeRateLimited        Code = "RATE_LIMITED"        // The request was throttled, by Stargate or a backend
+	CodeUnavailable        Code = "UNAVAILABLE"         // Stargate or a backend cannot serve requests for now
+	CodeTimeout            Code = "TIMEOUT"             // A backend did not answer in time
+	CodeBackendError       Code = "BACKEND_ERROR"       // A backend failed to handle the request
+	CodeBackendDenied      Code = "BACKEND_DENIED"      // A backend denied access to Stargate, a misconfiguration
+	CodeInternal           Code = "INTERNAL"            // Any other error
+)
+
+// codeInfo is the HTTP status and retryability of a code.
+type codeInfo struct {
+	status    int
+	retryable bool
+}
+
+var codes = map[Code]codeInfo{
+	CodeInvalidRequest:     {http.StatusBadRequest, false},
+	CodeUnauthorized:       {http.StatusUnauthorized, false},
+	CodeNotFound:           {http.StatusNotFound, false},
+	CodeConflict:           {http.StatusConflict, false},
+	CodePreconditionFailed: {http.StatusPreconditionFailed, false},
+	CodeChecksumMismatch:   {http.StatusBadRequest, true},
+	CodeTooLarge:           {http.StatusRequestEntityTooLarge, false},
+	CodeRevoked:            {http.StatusGone, false},
+	CodeRateLimited:        {http.StatusTooManyRequests, true},
+	CodeUnavailable:        {http.StatusServiceUnavailable, true},
+	CodeTimeout:            {http.StatusGatewayTimeout, true},
+	CodeBackendError:       {http.StatusBadGateway, true},
+	CodeBackendDenied:      {http.StatusBadGateway, false},
+	CodeInternal:           {http.StatusInternalServerError, false},
+}
+
+// Codes returns all codes, sorted.
+func Codes() []Code {
+	all := make([]Code, 0, len(codes))
+	for code := range codes {
+		all = append(all, code)
+	}
+	slices.Sort(all)
+	return all
+}
+
+// Error is an error returned by the API.
+type Error struct {
+	Code    Code
+	Message string // Safe to show to clients
+	Err     error  // The cause of the error, logged but never shown to clients
+}
+
+// New creates an error with a message shown to clients.
+func New(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Wrap creates an error caused by err, with a message shown to clients instead of the cause.
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status the error is served with.
+func (e *Error) Status() int {
+	if info, ok := codes[e.Code]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Retryable reports whether the same request may succeed if retried later.
+func (e *Error) Retryable() bool {
+	return codes[e.Code].retryable
+}
+
+// From maps any error to an Error. Errors other than Error are classified by their cause when it is known,
+// timeouts and network failures, and are internal errors otherwise.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Wrap(CodeTimeout, "backend timed out", err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return Wrap(CodeTimeout, "backend timed out", err)
+		}
+		return Wrap(CodeUnavailable, "backend unavailable", err)
+	}
+	return Wrap(CodeInternal, "internal error", err)
+}
@@ -0,0 +1,78 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCodes(t *testing.T) {
+	for code, want := range map[Code]codeInfo{
+		CodeInvalidRequest:     {http.StatusBadRequest, false},
+		CodeUnauthorized:       {http.StatusUnauthorized, false},
+		CodeNotFound:           {http.StatusNotFound, false},
+		CodeConflict:           {http.StatusConflict, false},
+		CodePreconditionFailed: {http.StatusPreconditionFailed, false},
+		CodeChecksumMismatch:   {http.StatusBadRequest, true},
+		CodeTooLarge:           {http.StatusRequestEntityTooLarge, false},
+		CodeRevoked:            {http.StatusGone, false},
+		CodeRateLimited:        {http.StatusTooManyRequests, true},
+		CodeUnavailable:        {http.StatusServiceUnavailable, true},
+		CodeTimeout:            {http.StatusGatewayTimeout, true},
+		CodeBackendError:       {http.StatusBadGateway, true},
+		CodeBackendDenied:      {http.StatusBadGateway, false},
+		CodeInternal:           {http.StatusInternalServerError, false},
+		"UNKNOWN":              {http.StatusInternalServerError, false},
+	} {
+		err := New(code, "message")
+		if status, retryable := err.Status(), err.Retryable(); status != want.status || retryable != want.retryable {
+			t.Errorf("%s: status %d, retryable %v, want %d, %v", code, status, retryable, want.status, want.retryable)
+		}
+	}
+	if got := len(Codes()); got != 14 {
+		t.Errorf("%d codes, want the 14 tested", got)
+	}
+}
+
+// netError is a net.Error, timing out or not.
+type netError struct{ timeout bool }
+
+func (e netError) Error() string   { return "network error" }
+func (e netError) Timeout() bool   { return e.timeout }
+func (e netError) Temporary() bool { return false }
+
+func TestFrom(t *testing.T) {
+	notFound := New(CodeNotFound, "object not found")
+	for name, tc := range map[string]struct {
+		err  error
+		code Code
+	}{
+		"API error":                 {notFound, CodeNotFound},
+		"wrapped API error":         {fmt.Errorf("get: %w", notFound), CodeNotFound},
+		"deadline exceeded":         {context.DeadlineExceeded, CodeTimeout},
+		"wrapped deadline exceeded": {fmt.Errorf("head: %w", context.DeadlineExceeded), CodeTimeout},
+		"network timeout":           {netError{timeout: true}, CodeTimeout},
+		"I/O timeout":               {&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, CodeTimeout},
+		"network failure":           {netError{}, CodeUnavailable},
+		"connection refused":        {&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, CodeUnavailable},
+		"canceled":                  {context.Canceled, CodeInternal},
+		"other error":               {errors.New("boom"), CodeInternal},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := From(tc.err)
+			if got.Code != tc.code {
+				t.Errorf("From(%v) = %s, want %s", tc.err, got.Code, tc.code)
+			}
+			if !errors.Is(got, tc.err) && !errors.Is(tc.err, got) {
+				t.Errorf("From(%v) neither returns nor wraps the error", tc.err)
+			}
+		})
+	}
+	if From(notFound) != notFound {
+		t.Error("From does not return API errors as is")
+	}
+}
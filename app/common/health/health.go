@@ -0,0 +1,138 @@
+// Package health runs the health checks of the components of the service, for liveness and readiness probes.
+package health
+
+import (
+	"cmp"
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the health status of a component or of the whole service.
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusDegraded means a non-critical component is failing, the service still works without it.
+	StatusDegraded Status = "degraded"
+	StatusFail     Status = "fail"
+)
+
+// ComponentStatus is the result of the last check of a component.
+type ComponentStatus struct {
+	Status    Status    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	LatencyMs float64   `json:"latencyMs"`
+}
+
+// Report is the health of the service and its components.
+type Report struct {
+	Status     Status                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// defaultTimeout bounds the checks registered without timeout.
+const defaultTimeout = 10 * time.Second
+
+// component is a registered check and its cached result.
+type component struct {
+	critical bool
+	ttl      time.Duration
+	timeout  time.Duration
+	check    func(ctx context.Context) error
+
+	mutex  sync.Mutex
+	result ComponentStatus
+}
+
+var (
+	components = make(map[string]*component)
+	mutex      sync.RWMutex
+)
+
+// Register adds a health check. A failing critical component makes the service not ready, while a failing
+// non-critical one only degrades it. The result is cached for ttl, so that probes do not hammer backends,
+// and a check taking longer than timeout, default 10s, fails.
+func Register(name string, critical bool, ttl, timeout time.Duration, check func(ctx context.Context) error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	components[name] = &component{
+		critical: critical,
+		ttl:      ttl,
+		timeout:  cmp.Or(timeout, defaultTimeout),
+		check:    check,
+	}
+}
+
+// Check runs all health checks concurrently, or returns their cached results.
+func Check() Report {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	report := Report{
+		Status:     StatusOK,
+		Components: make(map[string]ComponentStatus, len(components)),
+	}
+	var (
+		wg          sync.WaitGroup
+		reportMutex sync.Mutex
+	)
+	for name, comp := range components {
+		wg.Add(1)
+		go func(name string, comp *component) {
+			defer wg.Done()
+			result := comp.run()
+			reportMutex.Lock()
+			defer reportMutex.Unlock()
+			report.Components[name] = result
+			switch {
+			case result.Status == StatusOK:
+			case result.Critical:
+				report.Status = StatusFail
+			case report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}(name, comp)
+	}
+	wg.Wait()
+	return report
+}
+
+// run runs the check of the component unless its last result is still fresh.
+// The check is detached from the probe that triggered it, as its result is cached and shared by every probe:
+// a probe giving up early must not record a failure of the component.
+func (c *component) run() ComponentStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.result.CheckedAt.IsZero() && time.Since(c.result.CheckedAt) < c.ttl {
+		return c.result
+	}
+	checkCtx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	start := time.Now()
+	// Checks are expected to honor the context, but one that does not must not hang the probe
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(checkCtx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-checkCtx.Done():
+		err = checkCtx.Err()
+	}
+	c.result = ComponentStatus{
+		Status:    StatusOK,
+		Critical:  c.critical,
+		CheckedAt: start,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		c.result.Status = StatusFail
+		c.result.Error = err.Error()
+	}
+	return c.result
+}
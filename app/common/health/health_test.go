@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useComponents replaces the registered components for the duration of a test.
+func useComponents(t *testing.T) {
+	mutex.Lock()
+	saved := components
+	components = make(map[string]*component)
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		components = saved
+		mutex.Unlock()
+	})
+}
+
+func ok(context.Context) error   { return nil }
+func fail(context.Context) error { return errors.New("down") }
+
+func TestCheckAggregation(t *testing.T) {
+	type check struct {
+		critical bool
+		check    func(context.Context) error
+	}
+	for name, tc := range map[string]struct {
+		checks map[string]check
+		want   Status
+	}{
+		"no component":          {want: StatusOK},
+		"all ok":                {checks: map[string]check{"a": {true, ok}, "b": {false, ok}}, want: StatusOK},
+		"non-critical failing":  {checks: map[string]check{"a": {true, ok}, "b": {false, fail}}, want: StatusDegraded},
+		"critical failing":      {checks: map[string]check{"a": {true, fail}, "b": {false, ok}}, want: StatusFail},
+		"both kinds of failure": {checks: map[string]check{"a": {true, fail}, "b": {false, fail}, "c": {false, fail}}, want: StatusFail},
+	} {
+		t.Run(name, func(t *testing.T) {
+			useComponents(t)
+			for name, c := range tc.checks {
+				Register(name, c.critical, 0, 0, c.check)
+			}
+			report := Check()
+			if report.Status != tc.want {
+				t.Errorf("status %s, want %s", report.Status, tc.want)
+			}
+			if len(report.Components) != len(tc.checks) {
+				t.Fatalf("%d components reported, want %d", len(report.Components), len(tc.checks))
+			}
+			for name, c := range tc.checks {
+				result := report.Components[name]
+				failing := c.check(context.Background()) != nil
+				if (result.Status == StatusFail) != failing || (result.Error != "") != failing || result.Critical != c.critical {
+					t.Errorf("component %s reported as %+v", name, result)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCachesResults(t *testing.T) {
+	useComponents(t)
+	var cached, uncached, expiring atomic.Int32
+	Register("cached", true, time.Hour, 0, func(context.Context) error { cached.Add(1); return nil })
+	Register("uncached", true, 0, 0, func(context.Context) error { uncached.Add(1); return nil })
+	Register("expiring", true, 50*time.Millisecond, 0, func(context.Context) error { expiring.Add(1); return nil })
+
+	first := Check()
+	second := Check()
+	if cached.Load() != 1 || uncached.Load() != 2 || expiring.Load() != 1 {
+		t.Errorf("checks run %d, %d and %d times, want 1, 2 and 1", cached.Load(), uncached.Load(), expiring.Load())
+	}
+	if !first.Components["cached"].CheckedAt.Equal(second.Components["cached"].CheckedAt) {
+		t.Error("cached result not reused")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	Check()
+	if cached.Load() != 1 || expiring.Load() != 2 {
+		t.Errorf("checks run %d and %d times once the short TTL expired, want 1 and 2", cached.Load(), expiring.Load())
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	useComponents(t)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	var deadline atomic.Value
+	// The check ignores its context, the probe must not wait for it
+	Register("hanging", false, 0, 20*time.Millisecond, func(ctx context.Context) error {
+		d, _ := ctx.Deadline()
+		deadline.Store(d)
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	report := Check()
+	elapsed := time.Since(start)
+	if elapsed > time.Second {
+		t.Errorf("Check took %v with a hanging check", elapsed)
+	}
+	result := report.Components["hanging"]
+	if result.Status != StatusFail || result.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("hanging check reported as %+v, want failed on its timeout", result)
+	}
+	if d, _ := deadline.Load().(time.Time); d.IsZero() || d.Sub(start) > 20*time.Millisecond+elapsed {
+		t.Errorf("check deadline %v after the probe, want the 20ms timeout", d.Sub(start))
+	}
+}
+
+func TestCheckDefaultTimeout(t *testing.T) {
+	useComponents(t)
+	var remaining time.Duration
+	Register("a", true, 0, 0, func(ctx context.Context) error {
+		d, _ := ctx.Deadline()
+		remaining = time.Until(d)
+		return nil
+	})
+	Check()
+	if remaining <= defaultTimeout-time.Second || remaining > defaultTimeout {
+		t.Errorf("check given %v, want the default timeout of %v", remaining, defaultTimeout)
+	}
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+type fieldsKey struct{}
+
+// fields holds the fields of a request, shared by all the contexts derived from the request context, so that fields
+// added by a handler are also logged by the middleware that created them.
+type fields struct {
+	mutex sync.Mutex
+	attrs []slog.Attr
+}
+
+// WithFields returns a context whose log lines carry the given fields, in addition to the fields of ctx.
+func WithFields(ctx context.Context, attrs ...slog.Attr) context.Context {
+	f := &fields{attrs: append(fieldsFromContext(ctx), attrs...)}
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// AddFields adds fields to the log lines of the request of ctx, including the lines of the middleware.
+// It does nothing if ctx does not come from a request.
+func AddFields(ctx context.Context, attrs ...slog.Attr) {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, attr := range attrs {
+		f.set(attr)
+	}
+}
+
+// set replaces the field of the same key if any, so that fields can be refined while handling a request.
+// Must be called with the mutex held.
+func (f *fields) set(attr slog.Attr) {
+	for i := range f.attrs {
+		if f.attrs[i].Key == attr.Key {
+			f.attrs[i] = attr
+			return
+		}
+	}
+	f.attrs = append(f.attrs, attr)
+}
+
+func fieldsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	attrs := make([]slog.Attr, len(f.attrs))
+	copy(attrs, f.attrs)
+	return attrs
+}
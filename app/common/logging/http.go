@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tundrawork/stargate/app/common/requestid"
+)
+
+// Middleware adds the request ID, trace ID, method and route to the log lines of every request, and logs its
+// status and duration once handled. It must run after the requestid and tracing middlewares.
+func Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		start := time.Now()
+		attrs := []slog.Attr{
+			slog.String("request_id", requestid.Get(c)),
+			slog.String("method", string(c.Method())),
+			slog.String("route", c.FullPath()),
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+		}
+		ctx = WithFields(ctx, attrs...)
+
+		c.Next(ctx)
+
+		AddFields(ctx,
+			slog.Int("status", c.Response.StatusCode()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		)
+		hlog.CtxInfof(ctx, "[HTTP] Request handled")
+	}
+}
@@ -0,0 +1,239 @@
+// Package logging backs hlog with log/slog, so that every line is structured and carries the fields of its request.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// levels maps hlog levels to slog levels, Trace, Notice and Fatal have no slog equivalent.
+var levels = map[hlog.Level]slog.Level{
+	hlog.LevelTrace:  slog.LevelDebug - 4,
+	hlog.LevelDebug:  slog.LevelDebug,
+	hlog.LevelInfo:   slog.LevelInfo,
+	hlog.LevelNotice: slog.LevelInfo + 2,
+	hlog.LevelWarn:   slog.LevelWarn,
+	hlog.LevelError:  slog.LevelError,
+	hlog.LevelFatal:  slog.LevelError + 4,
+}
+
+var levelNames = map[string]hlog.Level{
+	"trace":  hlog.LevelTrace,
+	"debug":  hlog.LevelDebug,
+	"info":   hlog.LevelInfo,
+	"notice": hlog.LevelNotice,
+	"warn":   hlog.LevelWarn,
+	"error":  hlog.LevelError,
+	"fatal":  hlog.LevelFatal,
+}
+
+// Init replaces the hlog logger with a structured one. It should be called right after the configuration is loaded,
+// before the server is created, so that all lines are structured.
+func Init(conf config.Logging) {
+	level := hlog.LevelInfo
+	if conf.Level != "" {
+		l, ok := levelNames[strings.ToLower(conf.Level)]
+		if !ok {
+			hlog.Fatalf("[Logging] Unknown level %q", conf.Level)
+		}
+		level = l
+	}
+	format := conf.Format
+	if format == "" {
+		format = FormatText
+	}
+	if format != FormatText && format != FormatJSON {
+		hlog.Fatalf("[Logging] Unknown format %q", conf.Format)
+	}
+
+	var output io.Writer
+	switch conf.Output {
+	case "", "stderr":
+		output = os.Stderr
+	case "stdout":
+		output = os.Stdout
+	default:
+		file, err := os.OpenFile(conf.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			hlog.Fatalf("[Logging] Error opening %s: %v", conf.Output, err)
+		}
+		output = file
+	}
+
+	logger := NewLogger(output, format)
+	logger.SetLevel(level)
+	hlog.SetLogger(logger)
+}
+
+// Logger is a hlog.FullLogger writing structured lines with log/slog.
+// Context-aware methods add the fields of the request to the line, and secrets are redacted from all lines.
+type Logger struct {
+	format string
+	level  slog.LevelVar
+	mutex  sync.RWMutex
+	logger *slog.Logger
+}
+
+// NewLogger creates a logger writing to output in the given format, FormatText or FormatJSON.
+func NewLogger(output io.Writer, format string) *Logger {
+	l := &Logger{format: format}
+	l.SetOutput(output)
+	return l
+}
+
+// SetLevel sets the lowest level of the lines written.
+func (l *Logger) SetLevel(level hlog.Level) {
+	l.level.Set(levels[level])
+}
+
+// SetOutput sets the writer lines are written to.
+func (l *Logger) SetOutput(output io.Writer) {
+	opts := &slog.HandlerOptions{
+		Level:       &l.level,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	if l.format == FormatJSON {
+		handler = slog.NewJSONHandler(output, opts)
+	} else {
+		handler = slog.NewTextHandler(output, opts)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.logger = slog.New(handler)
+}
+
+func (l *Logger) log(ctx context.Context, level hlog.Level, msg string) {
+	l.mutex.RLock()
+	logger := l.logger
+	l.mutex.RUnlock()
+
+	logger.LogAttrs(ctx, levels[level], RedactMessage(msg), fieldsFromContext(ctx)...)
+	if level == hlog.LevelFatal {
+		os.Exit(1)
+	}
+}
+
+// replaceAttr names the custom levels and redacts secret fields.
+func replaceAttr(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey {
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			switch level {
+			case levels[hlog.LevelTrace]:
+				attr.Value = slog.StringValue("TRACE")
+			case levels[hlog.LevelNotice]:
+				attr.Value = slog.StringValue("NOTICE")
+			case levels[hlog.LevelFatal]:
+				attr.Value = slog.StringValue("FATAL")
+			}
+		}
+		return attr
+	}
+	return redactAttr(attr)
+}
+
+func (l *Logger) Trace(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelTrace, fmt.Sprint(v...))
+}
+func (l *Logger) Debug(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelDebug, fmt.Sprint(v...))
+}
+func (l *Logger) Info(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelInfo, fmt.Sprint(v...))
+}
+func (l *Logger) Notice(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelNotice, fmt.Sprint(v...))
+}
+func (l *Logger) Warn(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelWarn, fmt.Sprint(v...))
+}
+func (l *Logger) Error(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelError, fmt.Sprint(v...))
+}
+func (l *Logger) Fatal(v ...interface{}) {
+	l.log(context.Background(), hlog.LevelFatal, fmt.Sprint(v...))
+}
+
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelTrace, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Noticef(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelNotice, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.log(context.Background(), hlog.LevelFatal, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxTracef(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelTrace, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxDebugf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxInfof(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxNoticef(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelNotice, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxWarnf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxErrorf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) CtxFatalf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, hlog.LevelFatal, fmt.Sprintf(format, v...))
+}
+
+// DebugWriter returns a writer logging every write as a debug line starting with prefix, for libraries writing their
+// own debug output, e.g. request dumps. The lines are redacted like all others.
+func DebugWriter(prefix string) io.Writer {
+	return debugWriter(prefix)
+}
+
+type debugWriter string
+
+func (w debugWriter) Write(p []byte) (int, error) {
+	hlog.Debugf("%s %s", string(w), strings.TrimSpace(string(p)))
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are the field names whose values are never logged.
+var secretKeys = map[string]bool{
+	"app_key":       true,
+	"x-app-key":     true,
+	"sign":          true,
+	"signature":     true,
+	"issuance":      true,
+	"token_auth":    true,
+	"secret_key":    true,
+	"authorization": true,
+}
+
+// secretPatterns match the secrets that may be embedded in messages: app keys, secret keys, the sign (s) and
+// issuance signature (v) query parameters of private URLs, and the COS request signatures of dumped requests.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(x-app-key["']?\s*[:=]\s*["']?)[^\s"',&]+`),
+	regexp.MustCompile(`(?i)(secret_?key["']?\s*[:=]\s*["']?)[^\s"',&]+`),
+	regexp.MustCompile(`([?&][sv]=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?)[^\r\n"']+`),
+	regexp.MustCompile(`(?i)([?&]q-signature=)[^&\s"']+`),
+}
+
+// RedactMessage removes secrets from a log message.
+func RedactMessage(msg string) string {
+	for _, pattern := range secretPatterns {
+		msg = pattern.ReplaceAllString(msg, "${1}"+redacted)
+	}
+	return msg
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindString {
+		if value := attr.Value.String(); value != RedactMessage(value) {
+			return slog.String(attr.Key, RedactMessage(value))
+		}
+	}
+	return attr
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+const secret = "s3cr3t"
+
+func TestRedactMessage(t *testing.T) {
+	for _, msg := range []string{
+		"X-App-Key: " + secret,
+		`{"x-app-key":"` + secret + `"}`,
+		"x-app-key=" + secret + "&path=/a.png",
+		"secret_key=" + secret,
+		`SecretKey: "` + secret + `"`,
+		"GET /a.png?s=" + secret + "&t=1700000000",
+		"GET /a.png?t=1700000000&v=" + secret,
+		"Authorization: q-sign-algorithm=sha1&q-ak=AKID&q-signature=" + secret,
+		"https://bucket.cos.ap-shanghai.myqcloud.com/a.png?q-ak=AKID&q-signature=" + secret + "&x=1",
+	} {
+		got := RedactMessage(msg)
+		if strings.Contains(got, secret) || !strings.Contains(got, redacted) {
+			t.Errorf("RedactMessage(%q) = %q", msg, got)
+		}
+	}
+
+	for _, msg := range []string{"GET /a.png?size=1&ts=2", "app key rotated", "sign in"} {
+		if got := RedactMessage(msg); got != msg {
+			t.Errorf("RedactMessage(%q) = %q, want it unchanged", msg, got)
+		}
+	}
+}
+
+func TestLoggerRedactsSecrets(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var output bytes.Buffer
+			logger := NewLogger(&output, format)
+			ctx := WithFields(context.Background(),
+				slog.String("x-app-key", secret),
+				slog.String("secret_key", secret),
+				slog.String("Authorization", secret),
+				slog.String("token_auth", secret),
+				slog.String("url", "/a.png?s="+secret+"&t=1"),
+				slog.String("app_id", "app-a"),
+			)
+			logger.CtxInfof(ctx, "Request X-App-Key=%s", secret)
+
+			line := output.String()
+			if strings.Contains(line, secret) {
+				t.Errorf("secret logged: %s", line)
+			}
+			if !strings.Contains(line, "app-a") {
+				t.Errorf("field app_id not logged: %s", line)
+			}
+		})
+	}
+}
+
+func TestDebugWriterRedactsRequestDumps(t *testing.T) {
+	var output bytes.Buffer
+	logger := NewLogger(&output, FormatText)
+	logger.SetLevel(hlog.LevelDebug)
+	hlog.SetLogger(logger)
+	t.Cleanup(func() { hlog.SetLogger(NewLogger(&bytes.Buffer{}, FormatText)) })
+
+	req, _ := http.NewRequest(http.MethodHead, "https://bucket.cos.ap-shanghai.myqcloud.com/a.png", nil)
+	req.Header.Set("Authorization", "q-sign-algorithm=sha1&q-ak=AKID&q-signature="+secret)
+	dump, _ := httputil.DumpRequest(req, false)
+	if _, err := DebugWriter("[COS]").Write(dump); err != nil {
+		t.Fatal(err)
+	}
+
+	line := output.String()
+	if strings.Contains(line, secret) || !strings.Contains(line, "[COS] HEAD /a.png") {
+		t.Errorf("request dump logged as %s", line)
+	}
+}
@@ -7,13 +7,20 @@ import (
 	"os"
 	"time"
 
+	"github.com/tundrawork/stargate/app/common/accesslog"
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/health"
 	"github.com/tundrawork/stargate/app/common/matomo"
+	"github.com/tundrawork/stargate/app/common/requestid"
+	"github.com/tundrawork/stargate/app/common/tracing"
 	"github.com/tundrawork/stargate/config"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
-	"github.com/hertz-contrib/requestid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type PingResponseData struct {
@@ -21,15 +28,54 @@ type PingResponseData struct {
 	RequestId string `json:"requestId"`
 }
 
+// configHealthTTL is how long the result of the configuration check is cached.
+const configHealthTTL = 10 * time.Second
+
 // Init initializes the common package.
 func Init() {
-	matomo.InitClient(
-		config.Conf.Matomo.Endpoint,
-		config.Conf.Matomo.AuthToken,
-		config.Conf.Matomo.NumWorkers,
-		config.Conf.Matomo.BatchSize,
-		config.Conf.Matomo.EventBufferSize,
-	)
+	tracing.Init(config.Conf.Tracing)
+	conf := config.Conf.Matomo
+	matomo.InitClient(conf.Endpoint, conf.AuthToken, analytics.MatomoOptions(conf)...)
+	registerMatomoMetrics()
+	// Not critical, the loaded configuration keeps being served while the modified file is broken
+	health.Register("config", false, configHealthTTL, 0, config.Check)
+	health.Register("matomo", false, 0, 0, func(_ context.Context) error {
+		return matomo.Health()
+	})
+	analytics.Init(config.Conf.Analytics)
+	accesslog.Init(config.Conf.AccessLog)
+}
+
+// registerMatomoMetrics exposes the counters of the default Matomo client as metrics.
+func registerMatomoMetrics() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "stargate_matomo_queue_depth",
+		Help: "Number of events waiting to be batched for Matomo.",
+	}, func() float64 { return float64(matomo.GetStats().Pending) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_events_queued_total",
+		Help: "Number of events accepted in the Matomo event buffer.",
+	}, func() float64 { return float64(matomo.GetStats().Queued) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_events_overflowed_total",
+		Help: "Number of events handed to the spool because the Matomo event buffer was full.",
+	}, func() float64 { return float64(matomo.GetStats().Overflowed) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_events_sent_total",
+		Help: "Number of events sent to Matomo.",
+	}, func() float64 { return float64(matomo.GetStats().Sent) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_events_dropped_total",
+		Help: "Number of events lost before reaching Matomo.",
+	}, func() float64 { return float64(matomo.GetStats().Dropped) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_events_spooled_total",
+		Help: "Number of events written to the on-disk spool.",
+	}, func() float64 { return float64(matomo.GetStats().Spooled) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "stargate_matomo_batch_retries_total",
+		Help: "Number of Matomo batch send retries.",
+	}, func() float64 { return float64(matomo.GetStats().Retried) })
 }
 
 // Ping returns environment information of the server.
@@ -42,6 +88,25 @@ func Ping(_ context.Context, c *app.RequestContext) {
 	}))
 }
 
+// Live reports that the server is running, regardless of the health of its dependencies.
+func Live(_ context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, APIResponseSuccess(nil))
+}
+
+// Ready reports the health of every component of the server, and fails if a critical one is failing.
+func Ready(_ context.Context, c *app.RequestContext) {
+	report := health.Check()
+	if report.Status == health.StatusFail {
+		c.JSON(consts.StatusServiceUnavailable, APIResponse{
+			Code:    consts.StatusServiceUnavailable,
+			Message: "not ready",
+			Data:    report,
+		})
+		return
+	}
+	c.JSON(consts.StatusOK, APIResponseSuccess(report))
+}
+
 // DocsHandler handles the request for the API documentation.
 func DocsHandler(_ context.Context, c *app.RequestContext) {
 	template := fmt.Sprintf("%s.tmpl", c.Param("file"))
@@ -56,7 +121,24 @@ func DocsHandler(_ context.Context, c *app.RequestContext) {
 	})
 }
 
+// swaggerUIAssets are the vendored Swagger UI files served for the OpenAPI documentation.
+var swaggerUIAssets = map[string]bool{
+	"swagger-ui-bundle.js": true,
+	"swagger-ui.css":       true,
+}
+
+// SwaggerUIHandler handles the request for the Swagger UI files used by the OpenAPI documentation.
+func SwaggerUIHandler(_ context.Context, c *app.RequestContext) {
+	asset := c.Param("asset")
+	if !swaggerUIAssets[asset] {
+		c.SetStatusCode(http.StatusNotFound)
+		c.SetBodyString("404 Not Found: The requested documentation file does not exist.")
+		return
+	}
+	c.File(fmt.Sprintf("docs/swagger-ui/%s", asset))
+}
+
 // InvalidAPIPathHandler handles the request for invalid API paths.
 func InvalidAPIPathHandler(_ context.Context, c *app.RequestContext) {
-	c.JSON(consts.StatusNotFound, APIResponseError(consts.StatusNotFound, "The requested API path does not exist."))
+	RenderError(c, apierror.New(apierror.CodeNotFound, "The requested API path does not exist."))
 }
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/tundrawork/stargate/app/common/health"
+)
+
+// TestReady checks that only a failing critical component makes the server not ready.
+func TestReady(t *testing.T) {
+	var criticalFailing, optionalFailing atomic.Bool
+	checkOf := func(failing *atomic.Bool) func(context.Context) error {
+		return func(context.Context) error {
+			if failing.Load() {
+				return errors.New("down")
+			}
+			return nil
+		}
+	}
+	health.Register("test-critical", true, 0, 0, checkOf(&criticalFailing))
+	health.Register("test-optional", false, 0, 0, checkOf(&optionalFailing))
+
+	h := server.New()
+	h.GET("/health/ready", Ready)
+	h.GET("/health/live", Live)
+	for _, tc := range []struct {
+		criticalFailing, optionalFailing bool
+		status                           int
+	}{
+		{false, false, http.StatusOK},
+		{false, true, http.StatusOK}, // Degraded
+		{true, false, http.StatusServiceUnavailable},
+		{true, true, http.StatusServiceUnavailable},
+	} {
+		criticalFailing.Store(tc.criticalFailing)
+		optionalFailing.Store(tc.optionalFailing)
+		if w := ut.PerformRequest(h.Engine, http.MethodGet, "/health/ready", nil); w.Code != tc.status {
+			t.Errorf("critical failing %v, optional failing %v: ready status %d, want %d", tc.criticalFailing, tc.optionalFailing, w.Code, tc.status)
+		}
+		if w := ut.PerformRequest(h.Engine, http.MethodGet, "/health/live", nil); w.Code != http.StatusOK {
+			t.Errorf("critical failing %v: live status %d, want 200", tc.criticalFailing, w.Code)
+		}
+	}
+}
@@ -3,15 +3,24 @@ package matomo
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/cloudwego/hertz/pkg/common/json"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Event represents a single event to be tracked by Matomo.
@@ -19,65 +28,474 @@ type Event struct {
 	SiteID     string    // Required: The site ID to track the event for
 	ActionName string    // Required: The name of the action being tracked
 	URL        string    // Required: The URL of the page/resource being accessed
-	UserAgent  string    // Required: The user's browser user agent
-	ClientIP   string    // Required: The user's IP address
+	UserAgent  string    // Optional: The user's browser user agent, may be stripped for privacy
+	ClientIP   string    // Optional: The user's IP address, may be anonymized for privacy
 	ClientTime time.Time // Required: The time of the event, in the client's timezone
+
+	Status           int            // Optional: The HTTP status of the response
+	RequestID        string         // Optional: The request ID, to correlate with logs and traces
+	ResponseSize     int64          // Optional: The size of the response body in bytes
+	BytesUploaded    int64          // Optional: The size of the request body in bytes
+	Duration         time.Duration  // Optional: The time taken to handle the request
+	Referer          string         // Optional: The referer of the request
+	AcceptLanguage   string         // Optional: The Accept-Language header of the request
+	CustomDimensions map[int]string // Optional: Custom dimension values by dimension ID
+	EventCategory    string         // Optional: The event category, the event is tracked as a Matomo event if set
+	EventAction      string         // Optional: The event action
+	EventName        string         // Optional: The event name
+	EventValue       float64        // Optional: The event value
 }
 
+// Client reports events to a Matomo server in batches, using a pool of background workers.
 type Client struct {
-	matomoURL   string
-	authToken   string
-	httpClient  *http.Client
-	eventChan   chan Event
-	stopChan    chan struct{}
-	workerGroup sync.WaitGroup
-	batchSize   int
+	matomoURL       string
+	authToken       string
+	httpClient      *http.Client
+	timeout         time.Duration
+	numWorkers      int
+	batchSize       int
+	eventBufferSize int
+	flushInterval   time.Duration
+	shutdownTimeout time.Duration
+	batchMaxBytes   int
+	policy          BackpressurePolicy
+	blockTimeout    time.Duration
+	sampleRate      float64
+	maxRetries      int
+	retryBaseDelay  time.Duration
+	retryMaxDelay   time.Duration
+	spoolDir        string
+	spoolMaxBytes   int64
+	eventChan       chan Event
+	overflowChan    chan Event // Events that did not fit in the event buffer, written to the spool in the background
+	stopChan        chan struct{}
+	drainCtx        context.Context // Bounds the delivery of the events still queued once stopping, set before stopChan is closed
+	closeOnce       sync.Once
+	closed          atomic.Bool
+	unreachable     atomic.Bool // The last batch failed with a retryable error
+	workerGroup     sync.WaitGroup
+	spool           *spool
+	stats           counters
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// BackpressurePolicy decides what happens to an event reported while the event buffer is full.
+// Whatever the policy, an event that cannot be queued is handed to the spool if one is configured, or dropped.
+// The spool is written in the background, and events overflowing faster than it is written are dropped.
+type BackpressurePolicy string
+
+const (
+	// PolicyDropNewest gives up on the event being reported.
+	PolicyDropNewest BackpressurePolicy = "drop_newest"
+	// PolicyDropOldest evicts the oldest queued event to make room for the event being reported.
+	PolicyDropOldest BackpressurePolicy = "drop_oldest"
+	// PolicyBlock waits up to the block timeout for room in the buffer, delaying the caller.
+	PolicyBlock BackpressurePolicy = "block"
+	// PolicySample keeps only a fraction of the events once the buffer is half full, shedding load before it
+	// overflows, and gives up on the event being reported once it is full.
+	PolicySample BackpressurePolicy = "sample"
+)
+
+// Stats holds the event counters of the Matomo client.
+type Stats struct {
+	Queued     int64 // Events accepted in the event buffer
+	Overflowed int64 // Events not fitting in, or evicted from, the full event buffer, and handed to the spool
+	Sent       int64 // Events successfully sent to Matomo
+	Dropped    int64 // Events lost, either not queued or failed and not spooled
+	Retried    int64 // Batch send attempts after a failed one
+	Spooled    int64 // Events written to the on-disk spool, failed batches and overflowed events
+	Pending    int   // Events waiting in the buffer for a worker
+}
+
+type counters struct {
+	queued     atomic.Int64
+	overflowed atomic.Int64
+	sent       atomic.Int64
+	dropped    atomic.Int64
+	retried    atomic.Int64
+	spooled    atomic.Int64
 }
 
+const (
+	spoolReplayInterval = 30 * time.Second
+	overflowBufferSize  = 1024
+	overflowBatchSize   = 100
+	spoolGracePeriod    = 1 * time.Second
+
+	defaultBatchSize       = 50
+	defaultEventBufferSize = 1000
+	defaultMaxRetries      = 3
+	defaultRetryBaseDelay  = 500 * time.Millisecond
+	defaultRetryMaxDelay   = 30 * time.Second
+	defaultTimeout         = 5 * time.Second
+	defaultFlushInterval   = 1 * time.Second
+	defaultShutdownTimeout = 10 * time.Second
+	defaultBlockTimeout    = 100 * time.Millisecond
+	defaultSampleRate      = 0.1
+
+	// tracerName is the name of the tracer of the batch spans, the client leaves tracing setup to the application.
+	tracerName = "github.com/tundrawork/stargate/app/common/matomo"
+)
+
+var (
+	// ErrClientClosed is returned when reporting to a closed client.
+	ErrClientClosed = errors.New("matomo client is closed")
+	// ErrBufferFull is returned when an event is dropped because the event buffer is full.
+	ErrBufferFull = errors.New("matomo event buffer is full")
+	// ErrSampledOut is returned when an event is dropped by the sample backpressure policy.
+	ErrSampledOut = errors.New("matomo event sampled out")
+)
+
 var (
-	clientInstance *Client
-	once           sync.Once
+	clientInstance atomic.Pointer[Client]
 )
 
-// InitClient initializes the Matomo client.
-// It should be called once, typically during application startup.
-func InitClient(matomoURL string, authToken string, numWorkers int, batchSize int, eventBufferSize int) {
-	once.Do(func() {
-		clientInstance = &Client{
-			matomoURL: matomoURL,
-			authToken: authToken,
-			httpClient: &http.Client{
-				Timeout: 5 * time.Second, // Set a reasonable timeout
-			},
-			eventChan: make(chan Event, eventBufferSize),
-			stopChan:  make(chan struct{}),
-			batchSize: batchSize,
+// WithHTTPClient sets the HTTP client used to send batches, overriding WithTimeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout of the requests sending batches.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
 		}
+	}
+}
 
-		if numWorkers <= 0 {
-			numWorkers = 1 // Default to 1 worker if an invalid value is provided
+// WithFlushInterval sets the interval at which incomplete batches are sent.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		if interval > 0 {
+			c.flushInterval = interval
 		}
+	}
+}
 
-		clientInstance.workerGroup.Add(numWorkers)
-		for i := 0; i < numWorkers; i++ {
-			go clientInstance.eventWorker(i)
+// WithShutdownTimeout sets how long Shutdown waits for pending batches to be sent or spooled.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.shutdownTimeout = timeout
 		}
-		hlog.Infof("[Matomo] Initialized client with %d workers, buffer size %d", numWorkers, eventBufferSize)
-	})
+	}
 }
 
-// ReportEvent queues an event for reporting to Matomo.
-func ReportEvent(ctx context.Context, event Event) {
-	if clientInstance == nil {
-		hlog.CtxErrorf(ctx, "[Matomo] ReportEvent called before InitMatomoClient")
-		return
+// WithBatchMaxBytes caps the size of the tracking requests in a batch, in addition to the number of events.
+// A single event larger than the cap is still sent, alone.
+func WithBatchMaxBytes(maxBytes int) Option {
+	return func(c *Client) {
+		if maxBytes > 0 {
+			c.batchMaxBytes = maxBytes
+		}
+	}
+}
+
+// WithBackpressurePolicy sets the policy applied when the event buffer is full.
+// blockTimeout is used by PolicyBlock and sampleRate, the fraction of events kept, by PolicySample.
+func WithBackpressurePolicy(policy BackpressurePolicy, blockTimeout time.Duration, sampleRate float64) Option {
+	return func(c *Client) {
+		if policy != "" {
+			c.policy = policy
+		}
+		if blockTimeout > 0 {
+			c.blockTimeout = blockTimeout
+		}
+		if sampleRate > 0 && sampleRate <= 1 {
+			c.sampleRate = sampleRate
+		}
+	}
+}
+
+// WithWorkers sets the number of workers sending batches.
+func WithWorkers(numWorkers int) Option {
+	return func(c *Client) {
+		if numWorkers > 0 {
+			c.numWorkers = numWorkers
+		}
+	}
+}
+
+// WithBatchSize sets the maximum number of events in a batch.
+func WithBatchSize(batchSize int) Option {
+	return func(c *Client) {
+		if batchSize > 0 {
+			c.batchSize = batchSize
+		}
+	}
+}
+
+// WithEventBufferSize sets the number of events that can be queued before the workers pick them up.
+func WithEventBufferSize(eventBufferSize int) Option {
+	return func(c *Client) {
+		if eventBufferSize > 0 {
+			c.eventBufferSize = eventBufferSize
+		}
+	}
+}
+
+// WithRetries sets how many times a failed batch is retried before it is spooled or dropped.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		if maxRetries >= 0 {
+			c.maxRetries = maxRetries
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry of a failed batch, doubled on every retry up to maxDelay.
+// The actual delays are drawn at random below them, so that clients do not retry in lockstep.
+func WithBackoff(baseDelay, maxDelay time.Duration) Option {
+	return func(c *Client) {
+		if baseDelay > 0 && maxDelay >= baseDelay {
+			c.retryBaseDelay = baseDelay
+			c.retryMaxDelay = maxDelay
+		}
+	}
+}
+
+// WithSpool enables the on-disk spool in dir for batches that still fail after all retries, bounded by maxBytes.
+// Spooled batches are replayed on startup and periodically afterward.
+func WithSpool(dir string, maxBytes int64) Option {
+	return func(c *Client) {
+		c.spoolDir = dir
+		c.spoolMaxBytes = maxBytes
+	}
+}
+
+// NewClient creates a Matomo client and starts its workers. It must be closed with Close.
+func NewClient(matomoURL string, authToken string, opts ...Option) *Client {
+	c := &Client{
+		matomoURL:       matomoURL,
+		authToken:       authToken,
+		timeout:         defaultTimeout,
+		numWorkers:      1,
+		batchSize:       defaultBatchSize,
+		eventBufferSize: defaultEventBufferSize,
+		maxRetries:      defaultMaxRetries,
+		retryBaseDelay:  defaultRetryBaseDelay,
+		retryMaxDelay:   defaultRetryMaxDelay,
+		flushInterval:   defaultFlushInterval,
+		shutdownTimeout: defaultShutdownTimeout,
+		policy:          PolicyDropNewest,
+		blockTimeout:    defaultBlockTimeout,
+		sampleRate:      defaultSampleRate,
+		stopChan:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: c.timeout,
+		}
+	}
+	switch c.policy {
+	case PolicyDropNewest, PolicyDropOldest, PolicyBlock, PolicySample:
+	default:
+		hlog.Warnf("[Matomo] Unknown backpressure policy %q, using %s", c.policy, PolicyDropNewest)
+		c.policy = PolicyDropNewest
+	}
+	c.eventChan = make(chan Event, c.eventBufferSize)
+
+	if c.spoolDir != "" {
+		s, err := openSpool(c.spoolDir, c.spoolMaxBytes)
+		if err != nil {
+			hlog.Errorf("[Matomo] Error opening spool, failed batches will be dropped: %v", err)
+		} else {
+			c.spool = s
+			c.overflowChan = make(chan Event, overflowBufferSize)
+			c.workerGroup.Add(2)
+			go c.spoolWorker()
+			go c.overflowWorker()
+		}
+	}
+
+	c.workerGroup.Add(c.numWorkers)
+	for i := 0; i < c.numWorkers; i++ {
+		go c.eventWorker(i)
+	}
+	hlog.Infof("[Matomo] Initialized client with %d workers, buffer size %d, backpressure policy %s",
+		c.numWorkers, c.eventBufferSize, c.policy)
+	return c
+}
+
+// Report queues an event for reporting to Matomo.
+func (c *Client) Report(ctx context.Context, event Event) error {
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.policy == PolicySample && len(c.eventChan) >= cap(c.eventChan)/2 && rand.Float64() >= c.sampleRate {
+		c.stats.dropped.Add(1)
+		return ErrSampledOut
 	}
 	select {
-	case clientInstance.eventChan <- event:
+	case c.eventChan <- event:
 		// Event successfully queued
+		c.stats.queued.Add(1)
+		return nil
 	default:
-		hlog.CtxWarnf(ctx, "[Matomo] Event buffer full, dropping event: %v", event)
 	}
+
+	switch c.policy {
+	case PolicyDropOldest:
+		select {
+		case oldest := <-c.eventChan:
+			_ = c.overflow(oldest)
+		default:
+		}
+		select {
+		case c.eventChan <- event:
+			c.stats.queued.Add(1)
+			return nil
+		default:
+		}
+	case PolicyBlock:
+		timer := time.NewTimer(c.blockTimeout)
+		defer timer.Stop()
+		select {
+		case c.eventChan <- event:
+			c.stats.queued.Add(1)
+			return nil
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-c.stopChan:
+		}
+	}
+
+	return c.overflow(event)
+}
+
+// overflow hands an event that does not fit in the event buffer to the overflow worker, so it is spooled and delivered
+// once the workers catch up. It drops the event if there is no spool or the overflow worker is behind.
+// It never writes to disk itself, so that a Matomo outage does not slow down the callers.
+func (c *Client) overflow(event Event) error {
+	if c.overflowChan != nil {
+		select {
+		case c.overflowChan <- event:
+			c.stats.overflowed.Add(1)
+			return nil
+		default:
+		}
+	}
+	c.stats.dropped.Add(1)
+	return ErrBufferFull
+}
+
+// Send sends a batch of events synchronously, retrying retryable failures, bypassing the event buffer and the spool.
+func (c *Client) Send(ctx context.Context, events []Event) error {
+	return c.sendBatchWithRetry(ctx, events)
+}
+
+// Stats returns a snapshot of the event counters of the client.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Queued:     c.stats.queued.Load(),
+		Overflowed: c.stats.overflowed.Load(),
+		Sent:       c.stats.sent.Load(),
+		Dropped:    c.stats.dropped.Load(),
+		Retried:    c.stats.retried.Load(),
+		Spooled:    c.stats.spooled.Load(),
+		Pending:    len(c.eventChan),
+	}
+}
+
+// Health returns an error if the client cannot deliver events in time: it is closed, its event buffer is nearly full,
+// or Matomo could not be reached for the last batch.
+func (c *Client) Health() error {
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+	if capacity := cap(c.eventChan); capacity > 0 && len(c.eventChan)*10 >= capacity*9 {
+		return fmt.Errorf("event buffer is %d%% full", len(c.eventChan)*100/capacity)
+	}
+	if c.unreachable.Load() {
+		return errors.New("matomo is unreachable, the last batch could not be delivered")
+	}
+	return nil
+}
+
+// Close stops the client. The workers deliver the batches they hold and the events still queued until ctx is done,
+// and spool what is left, or drop it if there is no spool. Close returns once they are done, or with the error of ctx
+// if they are still busy shortly after ctx is done.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.drainCtx = ctx
+		c.closed.Store(true)
+		close(c.stopChan) // Signal workers to stop
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.workerGroup.Wait() // Wait for all workers to finish
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	// Give the workers a moment to spool what they could not deliver in time
+	select {
+	case <-done:
+		return nil
+	case <-time.After(spoolGracePeriod):
+		return ctx.Err()
+	}
+}
+
+// InitClient initializes the default Matomo client used by the package functions.
+// Calling it again replaces the default client, closing the previous one, e.g. after the configuration is reloaded.
+func InitClient(matomoURL string, authToken string, opts ...Option) {
+	client := NewClient(matomoURL, authToken, opts...)
+	if previous := clientInstance.Swap(client); previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), previous.shutdownTimeout)
+			defer cancel()
+			if err := previous.Close(ctx); err != nil {
+				hlog.Errorf("[Matomo] Error closing previous client: %v", err)
+			}
+		}()
+	}
+}
+
+// ReportEvent queues an event for reporting to Matomo with the default client.
+func ReportEvent(ctx context.Context, event Event) {
+	client := clientInstance.Load()
+	if client == nil {
+		hlog.CtxErrorf(ctx, "[Matomo] ReportEvent called before matomo.InitClient")
+		return
+	}
+	if err := client.Report(ctx, event); err != nil {
+		hlog.CtxWarnf(ctx, "[Matomo] Error reporting event, dropping event: %v: %v", err, event)
+	}
+}
+
+// Health returns an error if the default client cannot deliver events in time, see Client.Health.
+func Health() error {
+	client := clientInstance.Load()
+	if client == nil {
+		return errors.New("matomo client is not initialized")
+	}
+	return client.Health()
+}
+
+// GetStats returns a snapshot of the event counters of the default client.
+func GetStats() Stats {
+	client := clientInstance.Load()
+	if client == nil {
+		return Stats{}
+	}
+	return client.Stats()
 }
 
 // eventWorker implements the worker goroutine that processes events from the channel.
@@ -86,53 +504,162 @@ func (c *Client) eventWorker(workerID int) {
 	hlog.Infof("[Matomo] Starting worker %d", workerID)
 
 	// Use a ticker for batch processing
-	ticker := time.NewTicker(1 * time.Second) // Send batches every second (or when full)
+	ticker := time.NewTicker(c.flushInterval)
 	defer ticker.Stop()
 
 	var batch []Event
+	var batchBytes int
 	for {
+		// Stopping takes precedence over the queued events, so that they are delivered within the close deadline
+		select {
+		case <-c.stopChan:
+			hlog.Infof("[Matomo] Stopping worker %d", workerID)
+			c.drain(batch, batchBytes)
+			return
+		default:
+		}
 		select {
 		case event := <-c.eventChan:
+			var size int
+			if c.batchMaxBytes > 0 {
+				size = len(trackingRequest(event))
+				if len(batch) > 0 && batchBytes+size > c.batchMaxBytes {
+					c.deliverBatch(context.Background(), batch)
+					batch, batchBytes = nil, 0 // Reset the batch
+				}
+			}
 			batch = append(batch, event)
-			if len(batch) >= c.batchSize {
-				c.sendBatch(context.Background(), batch)
-				batch = nil // Reset the batch
+			batchBytes += size
+			if len(batch) >= c.batchSize || (c.batchMaxBytes > 0 && batchBytes >= c.batchMaxBytes) {
+				c.deliverBatch(context.Background(), batch)
+				batch, batchBytes = nil, 0 // Reset the batch
 			}
 		case <-ticker.C:
 			if len(batch) > 0 {
-				c.sendBatch(context.Background(), batch)
-				batch = nil // Reset the batch
+				c.deliverBatch(context.Background(), batch)
+				batch, batchBytes = nil, 0 // Reset the batch
 			}
 		case <-c.stopChan:
-			hlog.Infof("[Matomo] Stopping worker %d", workerID)
-			// Send any remaining events before exiting
+		}
+	}
+}
+
+// drain delivers the batch being built and the events still queued once the client is stopping, in batches.
+// Once the close context is done, the remaining events are spooled without trying to send them.
+func (c *Client) drain(batch []Event, batchBytes int) {
+	ctx := c.drainCtx
+	flush := func() {
+		if ctx.Err() != nil {
+			c.spoolBatch(ctx, batch, fmt.Errorf("%w: %v", errRetryable, ctx.Err()))
+		} else {
+			c.deliverBatch(ctx, batch)
+		}
+		batch, batchBytes = nil, 0 // Reset the batch
+	}
+	for {
+		select {
+		case event := <-c.eventChan:
+			var size int
+			if c.batchMaxBytes > 0 {
+				size = len(trackingRequest(event))
+				if len(batch) > 0 && batchBytes+size > c.batchMaxBytes {
+					flush()
+				}
+			}
+			batch = append(batch, event)
+			batchBytes += size
+			if len(batch) >= c.batchSize || (c.batchMaxBytes > 0 && batchBytes >= c.batchMaxBytes) {
+				flush()
+			}
+		default:
 			if len(batch) > 0 {
-				c.sendBatch(context.Background(), batch)
+				flush()
 			}
 			return
 		}
 	}
 }
 
-// sendBatch implements the logic to send a batch of events to Matomo.
-func (c *Client) sendBatch(ctx context.Context, events []Event) {
+// deliverBatch sends a batch of events to Matomo with retries, and spools it if all attempts fail.
+func (c *Client) deliverBatch(ctx context.Context, events []Event) {
 	if len(events) == 0 {
 		return
 	}
+	if err := c.sendBatchWithRetry(ctx, events); err != nil {
+		c.spoolBatch(ctx, events, err)
+	}
+}
+
+// spoolBatch spools a batch of events that could not be delivered, or drops it if there is no spool or the error
+// is not retryable.
+func (c *Client) spoolBatch(ctx context.Context, events []Event, err error) {
+	if c.spool != nil && errors.Is(err, errRetryable) {
+		if err := c.spool.write(events); err != nil {
+			hlog.CtxErrorf(ctx, "[Matomo] Error spooling batch: %v", err)
+		} else {
+			c.stats.spooled.Add(int64(len(events)))
+			hlog.CtxWarnf(ctx, "[Matomo] Spooled batch of %d events for later delivery", len(events))
+			return
+		}
+	}
+	c.stats.dropped.Add(int64(len(events)))
+	hlog.CtxErrorf(ctx, "[Matomo] Dropped batch of %d events", len(events))
+}
+
+// sendBatchWithRetry sends a batch of events to Matomo, retrying retryable failures with exponential backoff and
+// full jitter. Retries are abandoned once the client is stopping, leaving the batch to the spool.
+func (c *Client) sendBatchWithRetry(ctx context.Context, events []Event) error {
+	err := c.sendBatch(ctx, events)
+	for attempt := 0; attempt < c.maxRetries && errors.Is(err, errRetryable); attempt++ {
+		select {
+		case <-time.After(c.retryDelay(attempt)):
+		case <-c.stopChan:
+			return err
+		case <-ctx.Done():
+			return err
+		}
+		c.stats.retried.Add(1)
+		err = c.sendBatch(ctx, events)
+	}
+	if err == nil {
+		c.stats.sent.Add(int64(len(events)))
+	}
+	return err
+}
+
+// retryDelay returns the delay before a retry, drawn at random below the base delay doubled on every attempt.
+func (c *Client) retryDelay(attempt int) time.Duration {
+	delay := c.retryBaseDelay << attempt
+	if delay > c.retryMaxDelay || delay <= 0 {
+		delay = c.retryMaxDelay
+	}
+	return rand.N(delay)
+}
+
+// errRetryable marks errors of sendBatch after which the batch may succeed if sent again.
+var errRetryable = errors.New("retryable")
+
+// sendBatch implements the logic to send a batch of events to Matomo.
+func (c *Client) sendBatch(ctx context.Context, events []Event) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "matomo.sendBatch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("matomo.batch_size", len(events))),
+	)
+	defer func() {
+		c.unreachable.Store(errors.Is(err, errRetryable))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
 	requests := make([]string, len(events))
 	for i, event := range events {
-		params := url.Values{}
-		params.Set("idsite", event.SiteID)
-		params.Set("rec", "1")
-		params.Set("action_name", event.ActionName)
-		params.Set("url", event.URL)
-		params.Set("ua", event.UserAgent)
-		params.Set("cip", event.ClientIP)
-		params.Set("cdt", event.ClientTime.UTC().Format("2006-01-02 15:04:05"))
-		params.Set("apiv", "1")
-		params.Set("rand", strconv.FormatInt(time.Now().UnixNano(), 10))
-		requests[i] = "?" + params.Encode()
+		requests[i] = trackingRequest(event)
 	}
 
 	payload := map[string]interface{}{
@@ -143,13 +670,19 @@ func (c *Client) sendBatch(ctx context.Context, events []Event) {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		hlog.CtxErrorf(ctx, "[Matomo] Error marshaling JSON: %v", err)
-		return
+		return err
 	}
 
-	resp, err := c.httpClient.Post(c.matomoURL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.matomoURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		hlog.CtxErrorf(ctx, "[Matomo] Error sending batch: %v", err)
-		return
+		return fmt.Errorf("%w: %v", errRetryable, err)
 	}
 	defer func(body io.ReadCloser) {
 		_ = body.Close() // Ignore error
@@ -157,33 +690,185 @@ func (c *Client) sendBatch(ctx context.Context, events []Event) {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		hlog.CtxErrorf(ctx, "[Matomo] Matomo returned non-OK status: %d", resp.StatusCode)
-		return
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("%w: status %d", errRetryable, resp.StatusCode)
+		}
+		return fmt.Errorf("status %d", resp.StatusCode)
 	}
 
 	hlog.CtxInfof(ctx, "[Matomo] Successfully sent batch of %d events", len(events))
+	return nil
 }
 
-// Shutdown gracefully shuts down the Matomo client, waiting for all pending events to be processed.
-func Shutdown(ctx context.Context) {
-	if clientInstance == nil {
-		return // Nothing to shut down
+// trackingRequest builds the query string tracking an event, as sent in a bulk tracking request.
+func trackingRequest(event Event) string {
+	params := url.Values{}
+	params.Set("idsite", event.SiteID)
+	params.Set("rec", "1")
+	params.Set("action_name", event.ActionName)
+	params.Set("url", event.URL)
+	if event.UserAgent != "" {
+		params.Set("ua", event.UserAgent)
+	}
+	if event.ClientIP != "" {
+		params.Set("cip", event.ClientIP)
+	}
+	params.Set("cdt", event.ClientTime.UTC().Format("2006-01-02 15:04:05"))
+	params.Set("apiv", "1")
+	params.Set("rand", strconv.FormatInt(time.Now().UnixNano(), 10))
+	if event.Duration > 0 {
+		params.Set("gt_ms", strconv.FormatInt(event.Duration.Milliseconds(), 10))
+	}
+	if transferred := event.ResponseSize + event.BytesUploaded; transferred > 0 {
+		params.Set("bw_bytes", strconv.FormatInt(transferred, 10))
+	}
+	if event.Referer != "" {
+		params.Set("urlref", event.Referer)
+	}
+	if event.AcceptLanguage != "" {
+		params.Set("lang", event.AcceptLanguage)
 	}
+	if variables := customVariables(event); len(variables) > 0 {
+		cvar, _ := json.Marshal(variables) // Never fails for a map of strings
+		params.Set("cvar", string(cvar))
+	}
+	for id, value := range event.CustomDimensions {
+		params.Set("dimension"+strconv.Itoa(id), value)
+	}
+	if event.EventCategory != "" {
+		params.Set("e_c", event.EventCategory)
+		params.Set("e_a", event.EventAction)
+		if event.EventName != "" {
+			params.Set("e_n", event.EventName)
+		}
+		if event.EventValue != 0 {
+			params.Set("e_v", strconv.FormatFloat(event.EventValue, 'f', -1, 64))
+		}
+	}
+	return "?" + params.Encode()
+}
 
-	hlog.CtxInfof(ctx, "[Matomo] Shutting down client...")
-	close(clientInstance.stopChan) // Signal workers to stop
+// customVariables returns the page-scope custom variables of an event, holding the fields Matomo has no parameter for.
+func customVariables(event Event) map[string][2]string {
+	variables := make(map[string][2]string)
+	if event.Status != 0 {
+		variables["1"] = [2]string{"status", strconv.Itoa(event.Status)}
+	}
+	if event.RequestID != "" {
+		variables["2"] = [2]string{"request_id", event.RequestID}
+	}
+	return variables
+}
 
-	done := make(chan struct{})
-	go func() {
-		clientInstance.workerGroup.Wait() // Wait for all workers to finish
-		close(done)
-	}()
+// overflowWorker writes the events that overflowed the event buffer to the spool, in batches of what is available.
+func (c *Client) overflowWorker() {
+	defer c.workerGroup.Done()
 
-	select {
-	case <-done:
-		hlog.CtxInfof(ctx, "[Matomo] Client shut down gracefully.")
-	case <-time.After(10 * time.Second): // Timeout after a reasonable period
-		hlog.CtxErrorf(ctx, "[Matomo] Client shutdown timed out.")
+	for {
+		select {
+		case event := <-c.overflowChan:
+			c.spoolOverflow(event)
+		case <-c.stopChan:
+			// Spool any remaining events before exiting
+			for {
+				select {
+				case event := <-c.overflowChan:
+					c.spoolOverflow(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// spoolOverflow writes an overflowed event to the spool, along with the other ones already waiting.
+func (c *Client) spoolOverflow(event Event) {
+	batch := []Event{event}
+drain:
+	for len(batch) < overflowBatchSize {
+		select {
+		case event := <-c.overflowChan:
+			batch = append(batch, event)
+		default:
+			break drain
+		}
+	}
+	if err := c.spool.write(batch); err != nil {
+		c.stats.dropped.Add(int64(len(batch)))
+		hlog.Errorf("[Matomo] Dropped %d overflowed events: %v", len(batch), err)
+		return
+	}
+	c.stats.spooled.Add(int64(len(batch)))
+}
+
+// spoolWorker replays spooled batches on startup and periodically afterward.
+func (c *Client) spoolWorker() {
+	defer c.workerGroup.Done()
+
+	ticker := time.NewTicker(spoolReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		c.replaySpool(context.Background())
+		select {
+		case <-ticker.C:
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// replaySpool sends spooled batches in order, stopping at the first batch that still fails.
+func (c *Client) replaySpool(ctx context.Context) {
+	for {
+		name, events, err := c.spool.oldest()
+		if err != nil {
+			hlog.CtxErrorf(ctx, "[Matomo] Error reading spool: %v", err)
+			return
+		}
+		if name == "" {
+			return // Spool is empty
+		}
+		if err := c.sendBatch(ctx, events); err != nil {
+			if errors.Is(err, errRetryable) {
+				return // Matomo is still unavailable, try again later
+			}
+			c.stats.dropped.Add(int64(len(events)))
+			hlog.CtxErrorf(ctx, "[Matomo] Dropped spooled batch of %d events: %v", len(events), err)
+		} else {
+			c.stats.sent.Add(int64(len(events)))
+		}
+		c.spool.remove(name)
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+	}
+}
+
+// Shutdown gracefully shuts down the default Matomo client, delivering the queued events until the shutdown timeout
+// and spooling the rest. The delivery stops early enough to spool the rest before ctx is done.
+func Shutdown(ctx context.Context) {
+	client := clientInstance.Swap(nil) // Reset the instance
+	if client == nil {
+		return // Nothing to shut down
 	}
 
-	clientInstance = nil // Reset the instance
+	hlog.CtxInfof(ctx, "[Matomo] Shutting down client with %d pending events...", len(client.eventChan))
+	deadline := time.Now().Add(client.shutdownTimeout)
+	if hookDeadline, ok := ctx.Deadline(); ok && hookDeadline.Add(-spoolGracePeriod).Before(deadline) {
+		deadline = hookDeadline.Add(-spoolGracePeriod)
+	}
+	closeCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	err := client.Close(closeCtx)
+	stats := client.Stats()
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[Matomo] Client shutdown timed out with %d events still pending.", stats.Pending)
+		return
+	}
+	hlog.CtxInfof(ctx, "[Matomo] Client shut down gracefully, %d events sent, %d spooled, %d dropped in total.",
+		stats.Sent, stats.Spooled, stats.Dropped)
 }
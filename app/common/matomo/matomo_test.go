@@ -0,0 +1,403 @@
+package matomo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/json"
+)
+
+func TestTrackingRequest(t *testing.T) {
+	event := Event{
+		SiteID:     "1",
+		ActionName: "railgun_cdn:server:GetURL",
+		URL:        "https://cdn.example.com/a.png",
+		ClientTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Status:     404,
+		RequestID:  "req-1",
+		Duration:   1500 * time.Millisecond,
+		EventName:  "/a.png",
+	}
+
+	params, err := url.ParseQuery(trackingRequest(event)[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"idsite": "1",
+		"url":    "https://cdn.example.com/a.png",
+		"cdt":    "2024-01-02 03:04:05",
+		"gt_ms":  "1500",
+		"cvar":   `{"1":["status","404"],"2":["request_id","req-1"]}`,
+	} {
+		if got := params.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if params.Has("e_c") || params.Has("e_n") {
+		t.Errorf("event without category tracked as a Matomo event: %v", params)
+	}
+
+	event.EventCategory, event.EventAction = "railgun_cdn", "server:GetURL"
+	params, _ = url.ParseQuery(trackingRequest(event)[1:])
+	if params.Get("e_c") != "railgun_cdn" || params.Get("e_a") != "server:GetURL" || params.Get("e_n") != "/a.png" {
+		t.Errorf("event with category not tracked as a Matomo event: %v", params)
+	}
+}
+
+// blockingServer is a Matomo server holding the first batch until release is closed, counting the events it receives.
+func blockingServer(t *testing.T) (server *httptest.Server, started <-chan struct{}, release chan struct{}, received *atomic.Int64) {
+	startedChan := make(chan struct{})
+	release = make(chan struct{})
+	received = new(atomic.Int64)
+	var once sync.Once
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Requests []string `json:"requests"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		once.Do(func() {
+			close(startedChan)
+			<-release
+		})
+		received.Add(int64(len(payload.Requests)))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, startedChan, release, received
+}
+
+// queueBehindBlockedBatch reports events while the only worker is stuck on the first batch, so they stay queued.
+func queueBehindBlockedBatch(t *testing.T, client *Client, started <-chan struct{}, count int) {
+	ctx := context.Background()
+	if err := client.Report(ctx, Event{SiteID: "1", ActionName: "first"}); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	<-started
+	for i := 1; i < count; i++ {
+		if err := client.Report(ctx, Event{SiteID: "1", ActionName: "queued"}); err != nil {
+			t.Fatalf("Report: %v", err)
+		}
+	}
+}
+
+func TestCloseDeliversQueuedEvents(t *testing.T) {
+	server, started, release, received := blockingServer(t)
+	client := NewClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour))
+	queueBehindBlockedBatch(t, client, started, 7)
+
+	closed := make(chan error)
+	go func() { closed <- client.Close(context.Background()) }()
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := received.Load(); got != 7 {
+		t.Errorf("Matomo received %d events, want 7", got)
+	}
+	if stats := client.Stats(); stats.Sent != 7 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want 7 sent and none dropped", stats)
+	}
+}
+
+func TestCloseSpoolsUndeliveredEvents(t *testing.T) {
+	server, started, release, _ := blockingServer(t)
+	client := NewClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour), WithSpool(t.TempDir(), 0))
+	queueBehindBlockedBatch(t, client, started, 6)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.Close(ctx); err == nil {
+		t.Fatal("Close returned before the blocked batch was delivered")
+	}
+	close(release)
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stats := client.Stats(); stats.Sent != 1 || stats.Spooled != 5 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want 1 sent, 5 spooled and none dropped", stats)
+	}
+}
+
+func TestShutdownFlushesQueuedEvents(t *testing.T) {
+	server, started, release, received := blockingServer(t)
+	InitClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour))
+	queueBehindBlockedBatch(t, clientInstance.Load(), started, 5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go close(release)
+	Shutdown(ctx)
+	if got := received.Load(); got != 5 {
+		t.Errorf("Matomo received %d events, want 5", got)
+	}
+}
+
+// fillBuffer blocks the only worker on the first batch and fills the event buffer of size behind it.
+func fillBuffer(t *testing.T, client *Client, started <-chan struct{}, size int) {
+	t.Helper()
+	queueBehindBlockedBatch(t, client, started, size+1)
+	if pending := client.Stats().Pending; pending != size {
+		t.Fatalf("%d events pending, want a full buffer of %d", pending, size)
+	}
+}
+
+func TestBackpressurePolicies(t *testing.T) {
+	const bufferSize = 2
+	for _, tc := range []struct {
+		policy BackpressurePolicy
+		spool  bool
+		err    error
+		stats  Stats // Counters once the full buffer is delivered
+	}{
+		{policy: PolicyDropNewest, err: ErrBufferFull, stats: Stats{Queued: 3, Sent: 3, Dropped: 1}},
+		{policy: PolicyDropNewest, spool: true, stats: Stats{Queued: 3, Overflowed: 1, Sent: 4, Spooled: 1}},
+		{policy: PolicyDropOldest, stats: Stats{Queued: 4, Sent: 3, Dropped: 1}},
+		{policy: PolicyDropOldest, spool: true, stats: Stats{Queued: 4, Overflowed: 1, Sent: 4, Spooled: 1}},
+		{policy: PolicyBlock, err: ErrBufferFull, stats: Stats{Queued: 3, Sent: 3, Dropped: 1}},
+	} {
+		name := string(tc.policy)
+		if tc.spool {
+			name += " with spool"
+		}
+		t.Run(name, func(t *testing.T) {
+			server, started, release, received := blockingServer(t)
+			opts := []Option{
+				WithBatchSize(1),
+				WithFlushInterval(time.Hour),
+				WithEventBufferSize(bufferSize),
+				WithBackpressurePolicy(tc.policy, 10*time.Millisecond, 1e-9),
+			}
+			if tc.spool {
+				opts = append(opts, WithSpool(t.TempDir(), 0))
+			}
+			client := NewClient(server.URL, "token", opts...)
+			fillBuffer(t, client, started, bufferSize)
+
+			if err := client.Report(context.Background(), Event{SiteID: "1", ActionName: "overflowing"}); err != tc.err {
+				t.Errorf("Report = %v, want %v", err, tc.err)
+			}
+			close(release)
+			if err := client.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if tc.spool {
+				// Replay the spool, as the next client would on startup
+				replaying := NewClient(server.URL, "token", WithSpool(client.spoolDir, 0))
+				waitFor(t, func() bool { return received.Load() == tc.stats.Sent })
+				_ = replaying.Close(context.Background())
+				tc.stats.Sent -= replaying.Stats().Sent
+			}
+			if stats := client.Stats(); stats != tc.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tc.stats)
+			}
+		})
+	}
+}
+
+func TestBlockPolicyWaitsForRoom(t *testing.T) {
+	server, started, release, received := blockingServer(t)
+	client := NewClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour), WithEventBufferSize(1),
+		WithBackpressurePolicy(PolicyBlock, 5*time.Second, 0))
+	fillBuffer(t, client, started, 1)
+
+	reported := make(chan error)
+	go func() { reported <- client.Report(context.Background(), Event{SiteID: "1", ActionName: "blocked"}) }()
+	select {
+	case err := <-reported:
+		t.Fatalf("Report returned %v while the buffer was full", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-reported; err != nil {
+		t.Errorf("Report = %v once the worker made room", err)
+	}
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := received.Load(); got != 3 {
+		t.Errorf("Matomo received %d events, want 3", got)
+	}
+}
+
+// TestSamplePolicy checks the sample policy, which sheds load from half full, before the buffer is full.
+func TestSamplePolicy(t *testing.T) {
+	server, started, release, _ := blockingServer(t)
+	client := NewClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour), WithEventBufferSize(4),
+		WithBackpressurePolicy(PolicySample, 0, 1e-9))
+	queueBehindBlockedBatch(t, client, started, 3) // Below half full, nothing is sampled out
+	if err := client.Report(context.Background(), Event{SiteID: "1"}); err != ErrSampledOut {
+		t.Errorf("Report = %v at half full, want %v", err, ErrSampledOut)
+	}
+	close(release)
+	_ = client.Close(context.Background())
+	if stats := client.Stats(); stats.Queued != 3 || stats.Dropped != 1 {
+		t.Errorf("stats = %+v, want 3 queued and 1 sampled out", stats)
+	}
+}
+
+// batchRecorder is a Matomo server recording the number of events of every batch it receives.
+func batchRecorder(t *testing.T, status func(batch int) int) (*httptest.Server, func() []int) {
+	var (
+		mutex   sync.Mutex
+		batches []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Requests []string `json:"requests"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mutex.Lock()
+		batches = append(batches, len(payload.Requests))
+		batch := len(batches)
+		mutex.Unlock()
+		w.WriteHeader(status(batch))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return slices.Clone(batches)
+	}
+}
+
+func TestBatchMaxBytes(t *testing.T) {
+	event := Event{SiteID: "1", ActionName: "event", ClientTime: time.Now()}
+	size := len(trackingRequest(event))
+	for name, tc := range map[string]struct {
+		maxBytes int
+		batches  []int
+	}{
+		"two events per batch":           {maxBytes: size*2 + size/2, batches: []int{2, 2, 1}},
+		"events larger than the maximum": {maxBytes: size / 2, batches: []int{1, 1, 1, 1, 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, batches := batchRecorder(t, func(int) int { return http.StatusNoContent })
+			client := NewClient(server.URL, "token", WithBatchSize(100), WithFlushInterval(time.Hour), WithBatchMaxBytes(tc.maxBytes))
+			for range 5 {
+				if err := client.Report(context.Background(), event); err != nil {
+					t.Fatalf("Report: %v", err)
+				}
+			}
+			if err := client.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got := batches(); !slices.Equal(got, tc.batches) {
+				t.Errorf("batches of %v events, want %v", got, tc.batches)
+			}
+		})
+	}
+}
+
+// waitFor waits up to 5s for condition to be true.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	for name, tc := range map[string]struct {
+		retries  int
+		failures int // Batches failing before Matomo recovers
+		status   int // Status of the failures
+		batches  []int
+		stats    Stats
+	}{
+		"succeeds after failures": {retries: 3, failures: 2, status: http.StatusServiceUnavailable, batches: []int{1, 1, 1}, stats: Stats{Queued: 1, Sent: 1, Retried: 2}},
+		"rate limited":            {retries: 3, failures: 1, status: http.StatusTooManyRequests, batches: []int{1, 1}, stats: Stats{Queued: 1, Sent: 1, Retried: 1}},
+		"fails every retry":       {retries: 2, failures: 5, status: http.StatusBadGateway, batches: []int{1, 1, 1}, stats: Stats{Queued: 1, Retried: 2, Dropped: 1}},
+		"not retried":             {retries: 3, failures: 1, status: http.StatusBadRequest, batches: []int{1}, stats: Stats{Queued: 1, Dropped: 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, batches := batchRecorder(t, func(batch int) int {
+				if batch <= tc.failures {
+					return tc.status
+				}
+				return http.StatusNoContent
+			})
+			client := NewClient(server.URL, "token", WithBatchSize(1), WithFlushInterval(time.Hour),
+				WithRetries(tc.retries), WithBackoff(time.Millisecond, 4*time.Millisecond))
+			if err := client.Report(context.Background(), Event{SiteID: "1", ActionName: "event"}); err != nil {
+				t.Fatalf("Report: %v", err)
+			}
+			// Retries are abandoned once closing, wait for the outcome of the batch first
+			waitFor(t, func() bool { stats := client.Stats(); return stats.Sent+stats.Dropped == 1 })
+			if err := client.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got := batches(); !slices.Equal(got, tc.batches) {
+				t.Errorf("batches of %v events, want %v", got, tc.batches)
+			}
+			if stats := client.Stats(); stats != tc.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tc.stats)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	client := &Client{retryBaseDelay: 10 * time.Millisecond, retryMaxDelay: 40 * time.Millisecond}
+	for attempt, limit := range map[int]time.Duration{
+		0:  10 * time.Millisecond,
+		1:  20 * time.Millisecond,
+		2:  40 * time.Millisecond,
+		3:  40 * time.Millisecond,
+		70: 40 * time.Millisecond, // The doubling overflows
+	} {
+		var longest time.Duration
+		for range 1000 {
+			delay := client.retryDelay(attempt)
+			if delay < 0 || delay >= limit {
+				t.Fatalf("attempt %d: delay %v, want below %v", attempt, delay, limit)
+			}
+			longest = max(longest, delay)
+		}
+		// Full jitter spreads the delays over the whole range
+		if longest < limit/2 {
+			t.Errorf("attempt %d: longest delay %v of %v", attempt, longest, limit)
+		}
+	}
+}
+
+func TestSpoolReplayedOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	previous, err := openSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, batch := range [][]Event{
+		{{SiteID: "1", ActionName: "first"}, {SiteID: "1", ActionName: "second"}},
+		{{SiteID: "1", ActionName: "third"}},
+	} {
+		if err := previous.write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server, batches := batchRecorder(t, func(int) int { return http.StatusNoContent })
+	InitClient(server.URL, "token", WithFlushInterval(time.Hour), WithSpool(dir, 0))
+	client := clientInstance.Load()
+	waitFor(t, func() bool { return len(batches()) == 2 })
+	Shutdown(context.Background())
+
+	if got := batches(); !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("replayed batches of %v events, want the spooled batches in order", got)
+	}
+	if stats := client.Stats(); stats.Sent != 3 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want the 3 spooled events sent", stats)
+	}
+	if names, err := previous.list(); err != nil || len(names) != 0 {
+		t.Errorf("spool holds %v after the replay (%v), want it empty", names, err)
+	}
+}
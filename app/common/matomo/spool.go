@@ -0,0 +1,141 @@
+package matomo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+)
+
+// spool is a bounded on-disk write-ahead log of event batches that could not be delivered.
+// Each batch is stored in its own file, named so that lexical order is write order.
+type spool struct {
+	dir      string
+	maxBytes int64
+	mutex    sync.Mutex
+	size     int64
+	seq      uint64
+}
+
+const (
+	spoolFileExt         = ".json"
+	defaultSpoolMaxBytes = 64 << 20
+)
+
+var errSpoolFull = errors.New("spool is full")
+
+// openSpool opens the spool in the given directory, creating it if needed.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+	s := &spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+	names, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			s.size += info.Size()
+		}
+	}
+	hlog.Infof("[Matomo] Opened spool with %d batches (%d bytes) in %s", len(names), s.size, dir)
+	return s, nil
+}
+
+// write appends a batch of events to the spool.
+func (s *spool) write(events []Event) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.size+int64(len(data)) > s.maxBytes {
+		return errSpoolFull
+	}
+	s.seq++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), s.seq, spoolFileExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		_ = os.Remove(tmp) // Ignore error
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(tmp) // Ignore error
+		return err
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+// oldest reads the oldest batch in the spool, returning an empty name if the spool is empty.
+// Unreadable batches are removed, so they cannot block the spool forever.
+func (s *spool) oldest() (string, []Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names, err := s.list()
+	if err != nil || len(names) == 0 {
+		return "", nil, err
+	}
+	name := names[0]
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return "", nil, err
+	}
+	var events []Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		s.removeLocked(name)
+		return "", nil, fmt.Errorf("corrupted batch %s removed: %w", name, err)
+	}
+	return name, events, nil
+}
+
+// remove deletes a batch from the spool.
+func (s *spool) remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeLocked(name)
+}
+
+func (s *spool) removeLocked(name string) {
+	path := filepath.Join(s.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		hlog.Errorf("[Matomo] Error removing spooled batch %s: %v", name, err)
+		return
+	}
+	s.size -= info.Size()
+}
+
+// list returns the names of all batches in the spool, oldest first.
+func (s *spool) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spoolFileExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,60 @@
+package matomo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tundrawork/stargate/app/common/tracing"
+)
+
+func TestSendBatchSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.InitWithExporter(exporter, "", nil)
+	t.Cleanup(func() { tracing.Shutdown(context.Background()) })
+	status := http.StatusOK
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "token", WithRetries(0))
+	defer func() { _ = client.Close(context.Background()) }()
+
+	ctx := context.Background()
+	events := []Event{{SiteID: "1", ActionName: "a"}, {SiteID: "1", ActionName: "b"}}
+	if err := client.Send(ctx, events); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	status = http.StatusBadRequest
+	if err := client.Send(ctx, events[:1]); err == nil {
+		t.Fatal("Send succeeded on a rejected batch")
+	}
+	tracing.ForceFlush(ctx)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2: %+v", len(spans), spans)
+	}
+	sent, rejected := spans[0], spans[1]
+	if sent.Name != "matomo.sendBatch" || sent.SpanKind != trace.SpanKindClient {
+		t.Errorf("batch span = %s (%v), want matomo.sendBatch (client)", sent.Name, sent.SpanKind)
+	}
+	if !slices.Contains(sent.Attributes, attribute.Int("matomo.batch_size", 2)) || sent.Status.Code == codes.Error {
+		t.Errorf("sent batch span: attributes %v, status %v", sent.Attributes, sent.Status)
+	}
+	if rejected.Status.Code != codes.Error {
+		t.Errorf("rejected batch span status = %v, want error", rejected.Status)
+	}
+	if traceparent == "" {
+		t.Error("batch request does not carry the trace context")
+	}
+}
@@ -0,0 +1,68 @@
+// Package metrics exposes the Prometheus metrics of the default registry, and records the metrics of HTTP requests.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/adaptor"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// tenantKey is the request context key holding the tenant of a request, for labeling its metrics.
+const tenantKey = "metrics:tenant"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stargate_http_requests_total",
+		Help: "Number of HTTP requests handled, by method, route, status and tenant.",
+	}, []string{"method", "route", "status", "app_id"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stargate_http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by method, route and tenant.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "app_id"})
+
+	promHandler = promhttp.Handler()
+)
+
+// Middleware records the count and latency of every request.
+// Requests are labeled with their route rather than their path, to bound the number of series.
+func Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		start := time.Now()
+		c.Next(ctx)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := string(c.Method())
+		appID := c.GetString(tenantKey)
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Response.StatusCode()), appID).Inc()
+		httpRequestDuration.WithLabelValues(method, route, appID).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetTenant labels the metrics of a request with its tenant. It must only be called with authenticated app IDs,
+// so that clients cannot create series at will.
+func SetTenant(c *app.RequestContext, appID string) {
+	c.Set(tenantKey, appID)
+}
+
+// Handler serves the metrics of the default Prometheus registry.
+func Handler(ctx context.Context, c *app.RequestContext) {
+	req, err := adaptor.GetCompatRequest(&c.Request)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[Metrics] Error adapting request: %v", err)
+		c.AbortWithStatus(consts.StatusInternalServerError)
+		return
+	}
+	promHandler.ServeHTTP(adaptor.GetCompatResponseWriter(&c.Response), req.WithContext(ctx))
+}
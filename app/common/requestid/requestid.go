@@ -0,0 +1,31 @@
+// Package requestid identifies every request with the ID given in its X-Request-ID header, or a generated one, which
+// is returned in the response. It replaces hertz-contrib/requestid, whose middleware writes a package variable on
+// every request, a data race between concurrent requests.
+package requestid
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/google/uuid"
+)
+
+// HeaderKey is the header carrying the request ID, in requests and responses.
+const HeaderKey = "X-Request-ID"
+
+// New returns the middleware identifying requests.
+func New() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		id := string(c.GetHeader(HeaderKey))
+		if id == "" {
+			id = uuid.NewString()
+		}
+		c.Header(HeaderKey, id)
+		c.Next(ctx)
+	}
+}
+
+// Get returns the ID of a request, empty if the middleware did not run.
+func Get(c *app.RequestContext) string {
+	return string(c.Response.Header.Peek(HeaderKey))
+}
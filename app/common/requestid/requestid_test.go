@@ -0,0 +1,29 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func TestNew(t *testing.T) {
+	h := server.New()
+	h.Use(New())
+	var got string
+	h.GET("/", func(_ context.Context, c *app.RequestContext) {
+		got = Get(c)
+	})
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/", nil, ut.Header{Key: HeaderKey, Value: "from-client"})
+	if got != "from-client" || w.Header().Get(HeaderKey) != "from-client" {
+		t.Errorf("request ID = %q, response header = %q, want the ID of the request", got, w.Header().Get(HeaderKey))
+	}
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/", nil)
+	if got == "" || w.Header().Get(HeaderKey) != got {
+		t.Errorf("request ID = %q, response header = %q, want a generated ID", got, w.Header().Get(HeaderKey))
+	}
+}
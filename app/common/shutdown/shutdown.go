@@ -0,0 +1,137 @@
+// Package shutdown drains the server before it stops: it fails readiness, rejects new uploads and waits for in-flight
+// requests, so that Hertz only shuts down and flushes analytics once no request can still produce events.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/health"
+	"github.com/tundrawork/stargate/config"
+)
+
+const (
+	defaultDrainTimeout = 30 * time.Second
+	defaultHookTimeout  = 15 * time.Second
+
+	progressInterval = 1 * time.Second
+)
+
+var (
+	readinessDelay time.Duration
+	drainTimeout   = defaultDrainTimeout
+	hookTimeout    = defaultHookTimeout
+
+	draining atomic.Bool
+	inFlight atomic.Int64
+)
+
+// Init configures the shutdown sequence and registers the readiness check failing while draining.
+func Init(conf config.Shutdown) {
+	readinessDelay = time.Duration(conf.ReadinessDelayMs) * time.Millisecond
+	if conf.DrainTimeoutMs > 0 {
+		drainTimeout = time.Duration(conf.DrainTimeoutMs) * time.Millisecond
+	}
+	if conf.HookTimeoutMs > 0 {
+		hookTimeout = time.Duration(conf.HookTimeoutMs) * time.Millisecond
+	}
+	health.Register("shutdown", true, 0, 0, func(_ context.Context) error {
+		if draining.Load() {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	})
+}
+
+// HookTimeout is the time given to the shutdown hooks once requests are drained, to be used as the Hertz exit wait time.
+func HookTimeout() time.Duration {
+	return hookTimeout
+}
+
+// Route is a route of the server, Path being the registered path.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Middleware counts in-flight requests, and rejects the uploadRoutes, which start new uploads, once draining.
+// Other requests are still served, as they are short or continue uploads already started, and the load balancer
+// stops sending them once not ready.
+func Middleware(uploadRoutes ...Route) app.HandlerFunc {
+	rejected := make(map[Route]bool, len(uploadRoutes))
+	for _, route := range uploadRoutes {
+		rejected[route] = true
+	}
+	return func(ctx context.Context, c *app.RequestContext) {
+		if draining.Load() && rejected[Route{Method: string(c.Method()), Path: c.FullPath()}] {
+			c.Header("Connection", "close")
+			common.RenderError(c, apierror.New(apierror.CodeUnavailable, "server is shutting down"))
+			c.Abort()
+			return
+		}
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		c.Next(ctx)
+	}
+}
+
+// SignalWaiter waits for SIGINT or SIGTERM and drains the server, before letting Hertz shut down.
+// A second signal skips the rest of the draining. It is meant for Hertz.SetCustomSignalWaiter.
+func SignalWaiter(errCh chan error) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		hlog.Infof("[Shutdown] Received signal %s, draining", sig)
+	case err := <-errCh:
+		return err
+	}
+	Drain(signals)
+	return nil
+}
+
+// Drain marks the server not ready, waits for the readiness delay so that load balancers notice, then waits for
+// in-flight requests up to the drain timeout. Receiving from abort cuts the wait short.
+func Drain(abort <-chan os.Signal) {
+	draining.Store(true)
+	hlog.Infof("[Shutdown] Marked not ready, rejecting new uploads")
+
+	if readinessDelay > 0 {
+		select {
+		case <-time.After(readinessDelay):
+		case sig := <-abort:
+			hlog.Warnf("[Shutdown] Received signal %s, skipping draining", sig)
+			return
+		}
+	}
+
+	deadline := time.NewTimer(drainTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for inFlight.Load() > 0 {
+		select {
+		case <-ticker.C:
+			hlog.Infof("[Shutdown] Waiting for %d in-flight requests", inFlight.Load())
+		case <-deadline.C:
+			hlog.Warnf("[Shutdown] Drain timeout reached with %d in-flight requests, shutting down anyway", inFlight.Load())
+			return
+		case sig := <-abort:
+			hlog.Warnf("[Shutdown] Received signal %s with %d in-flight requests, shutting down anyway", sig, inFlight.Load())
+			return
+		}
+	}
+	hlog.Infof("[Shutdown] All in-flight requests finished")
+}
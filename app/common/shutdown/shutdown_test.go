@@ -0,0 +1,50 @@
+package shutdown
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func TestMiddlewareRejectsNewUploadsWhileDraining(t *testing.T) {
+	h := server.New()
+	h.Use(Middleware(
+		Route{Method: http.MethodPut, Path: "/object"},
+		Route{Method: http.MethodPost, Path: "/object/uploads"},
+	))
+	ok := func(_ context.Context, c *app.RequestContext) { c.SetStatusCode(http.StatusOK) }
+	h.PUT("/object", ok)
+	h.POST("/object/uploads", ok)
+	h.PUT("/object/uploads/part", ok)
+	h.POST("/object/uploads/complete", ok)
+	h.GET("/object", ok)
+
+	draining.Store(true)
+	t.Cleanup(func() { draining.Store(false) })
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodPut, "/object", http.StatusServiceUnavailable},
+		{http.MethodPost, "/object/uploads", http.StatusServiceUnavailable},
+		// Uploads already started can still finish
+		{http.MethodPut, "/object/uploads/part", http.StatusOK},
+		{http.MethodPost, "/object/uploads/complete", http.StatusOK},
+		{http.MethodGet, "/object", http.StatusOK},
+	} {
+		w := ut.PerformRequest(h.Engine, tc.method, tc.path, nil)
+		if w.Code != tc.status {
+			t.Errorf("%s %s while draining: status %d, want %d", tc.method, tc.path, w.Code, tc.status)
+		}
+		if rejected := w.Header().Get("Connection") == "close"; rejected != (tc.status != http.StatusOK) {
+			t.Errorf("%s %s while draining: Connection %q", tc.method, tc.path, w.Header().Get("Connection"))
+		}
+	}
+	if n := inFlight.Load(); n != 0 {
+		t.Errorf("%d requests still counted in flight", n)
+	}
+}
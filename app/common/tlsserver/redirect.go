@@ -0,0 +1,30 @@
+package tlsserver
+
+import (
+	"context"
+	"net"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// NewRedirectServer creates a plain HTTP server on port redirecting every request to the same URL over HTTPS on
+// httpsPort. It must be run and shut down by the caller.
+func NewRedirectServer(port, httpsPort string) *server.Hertz {
+	h := server.New(
+		server.WithHostPorts(":"+port),
+		server.WithDisablePrintRoute(true),
+	)
+	h.NoRoute(func(_ context.Context, c *app.RequestContext) {
+		host := string(c.Host())
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		c.Redirect(consts.StatusPermanentRedirect, []byte("https://"+host+string(c.Request.RequestURI())))
+	})
+	return h
+}
@@ -0,0 +1,226 @@
+// Package tlsserver builds the TLS configuration of the server, reloading its certificate when the files change,
+// serves HTTP/2 next to HTTP/1.1, and exposes the client certificates of mutual TLS connections.
+package tlsserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/network/standard"
+	http2config "github.com/hertz-contrib/http2/config"
+	http2factory "github.com/hertz-contrib/http2/factory"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+
+	defaultReloadInterval = 10 * time.Second
+
+	// protocolHTTP2 is the ALPN protocol ID of HTTP/2, Hertz adds the one of HTTP/1.1 after it.
+	protocolHTTP2 = "h2"
+)
+
+// NewConfig builds the TLS configuration of the server, and starts watching the certificate files for changes.
+// Client certificates are verified against the client CA if one is configured, they are required only if
+// conf.ClientAuth is ClientAuthRequire, so that tenants may still authenticate with their app key.
+func NewConfig(conf config.TLS) (*tls.Config, error) {
+	reloader, err := newCertReloader(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	interval := defaultReloadInterval
+	if conf.ReloadIntervalMs > 0 {
+		interval = time.Duration(conf.ReloadIntervalMs) * time.Millisecond
+	}
+	go reloader.watch(interval)
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	if !conf.DisableHTTP2 {
+		tlsConfig.NextProtos = []string{protocolHTTP2}
+	}
+	if conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", conf.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		switch conf.ClientAuth {
+		case "", ClientAuthOptional:
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		case ClientAuthRequire:
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("unknown client auth %q", conf.ClientAuth)
+		}
+	} else if conf.ClientAuth != "" {
+		return nil, errors.New("client auth requires a client CA file")
+	}
+	return tlsConfig, nil
+}
+
+// ServerOptions returns the Hertz options serving TLS with the given configuration. TLS needs the standard transport,
+// as netpoll only serves plain connections, and ALPN to negotiate HTTP/2.
+func ServerOptions(tlsConfig *tls.Config) []hertzconfig.Option {
+	return []hertzconfig.Option{
+		server.WithTLS(tlsConfig),
+		server.WithALPN(true),
+		server.WithTransport(standard.NewTransporter),
+	}
+}
+
+// EnableHTTP2 serves HTTP/2 to the clients negotiating it, unless it is disabled. Request bodies are streamed to the
+// handlers like with HTTP/1.1.
+func EnableHTTP2(h *server.Hertz, conf config.TLS) {
+	if conf.DisableHTTP2 {
+		return
+	}
+	h.AddProtocol(protocolHTTP2, http2factory.NewServerFactory(
+		http2config.WithReadTimeout(time.Minute),
+		http2config.WithIdleTimeout(2*time.Minute),
+	))
+}
+
+// ClientCertificateNames returns the names of the verified client certificate of a request: its subject common name,
+// DNS names and URIs. It returns nil for plain connections and connections without a client certificate.
+func ClientCertificateNames(c *app.RequestContext) []string {
+	state, ok := connectionState(c.GetConn())
+	if !ok {
+		return nil
+	}
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	leaf := state.VerifiedChains[0][0]
+	var names []string
+	if leaf.Subject.CommonName != "" {
+		names = append(names, leaf.Subject.CommonName)
+	}
+	names = append(names, leaf.DNSNames...)
+	for _, uri := range leaf.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// connectionState returns the TLS state of a connection. The connections of HTTP/2 streams embed the TLS connection
+// they are multiplexed on in their Conn field, without exposing its state, so they are unwrapped first.
+func connectionState(conn network.Conn) (tls.ConnectionState, bool) {
+	for conn != nil {
+		if tlsConn, ok := conn.(network.ConnTLSer); ok {
+			return tlsConn.ConnectionState(), true
+		}
+		value := reflect.Indirect(reflect.ValueOf(conn))
+		if value.Kind() != reflect.Struct {
+			break
+		}
+		field := value.FieldByName("Conn")
+		if !field.IsValid() || !field.CanInterface() {
+			break
+		}
+		conn, _ = field.Interface().(network.Conn)
+	}
+	return tls.ConnectionState{}, false
+}
+
+// certReloader serves a certificate, reloading it when its files are modified, e.g. when renewed by an ACME client.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// watch polls the files for changes, polling rather than watching events so that symlink swaps are noticed too.
+func (r *certReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		modTime, err := r.latestModTime()
+		if err != nil {
+			hlog.Errorf("[TLS] Error checking certificate files: %v", err)
+			continue
+		}
+		r.mutex.RLock()
+		changed := modTime.After(r.modTime)
+		r.mutex.RUnlock()
+		if !changed {
+			continue
+		}
+		// Keep serving the current certificate if the new one is invalid, e.g. only one of the files is written yet
+		if err := r.reload(); err != nil {
+			hlog.Errorf("[TLS] Error reloading certificate, keeping the current one: %v", err)
+			continue
+		}
+		hlog.Infof("[TLS] Reloaded certificate from %s", r.certFile)
+	}
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
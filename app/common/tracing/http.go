@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tundrawork/stargate/app/common/requestid"
+)
+
+// Middleware starts a server span for every request, continuing the trace of the traceparent header if any.
+// The span carries the ID set by the requestid middleware, which must run first, and the trace ID is returned in the
+// X-Trace-Id response header, so that a request can be looked up from either ID.
+func Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, &headerCarrier{header: &c.Request.Header})
+		ctx, span := Tracer().Start(ctx, string(c.Method()),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", string(c.Method())),
+				attribute.String("url.path", string(c.Path())),
+				attribute.String("stargate.request_id", requestid.Get(c)),
+			),
+		)
+		defer span.End()
+		if span.SpanContext().HasTraceID() {
+			c.Header("X-Trace-Id", span.SpanContext().TraceID().String())
+		}
+
+		c.Next(ctx)
+
+		status := c.Response.StatusCode()
+		if route := c.FullPath(); route != "" {
+			span.SetName(string(c.Method()) + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+	}
+}
+
+// headerCarrier adapts Hertz request headers to a propagation.TextMapCarrier.
+type headerCarrier struct {
+	header *protocol.RequestHeader
+}
+
+func (h *headerCarrier) Get(key string) string {
+	return string(h.header.Peek(key))
+}
+
+func (h *headerCarrier) Set(key, value string) {
+	h.header.Set(key, value)
+}
+
+func (h *headerCarrier) Keys() []string {
+	var keys []string
+	h.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,113 @@
+// Package tracing sets up OpenTelemetry tracing, exporting spans over OTLP/HTTP and propagating W3C trace context.
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+// TracerName is the name of the tracer of all Stargate spans.
+const TracerName = "github.com/tundrawork/stargate"
+
+const defaultServiceName = "stargate"
+
+var (
+	provider *sdktrace.TracerProvider
+	mutex    sync.Mutex
+)
+
+func init() {
+	// Propagate trace context even when tracing is disabled, so traces are not broken by passing through Stargate.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init sets up tracing with an OTLP/HTTP exporter. It does nothing if tracing is disabled, leaving the no-op tracer.
+func Init(conf config.Tracing) {
+	if !conf.Enabled {
+		hlog.Infof("[Tracing] Tracing disabled")
+		return
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithHeaders(conf.Headers),
+	}
+	if conf.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(conf.Endpoint))
+	}
+	if conf.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		hlog.Fatalf("[Tracing] Error creating OTLP exporter: %v", err)
+	}
+	sampleRatio := conf.SampleRatio
+	if sampleRatio <= 0 {
+		sampleRatio = 1
+	}
+	InitWithExporter(exporter, conf.ServiceName, sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio)))
+	hlog.Infof("[Tracing] Exporting spans to %s, sample ratio %g", conf.Endpoint, sampleRatio)
+}
+
+// InitWithExporter sets up tracing with any exporter, e.g. an in-memory one from the sdk/trace/tracetest package
+// to check the spans of requests. A nil sampler samples every trace.
+func InitWithExporter(exporter sdktrace.SpanExporter, serviceName string, sampler sdktrace.Sampler) {
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	if sampler == nil {
+		sampler = sdktrace.AlwaysSample()
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	provider = tp
+	otel.SetTracerProvider(tp)
+}
+
+// Tracer returns the tracer of Stargate spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// ForceFlush exports the pending spans, e.g. before checking the spans recorded by an in-memory exporter.
+func ForceFlush(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if provider == nil {
+		return
+	}
+	if err := provider.ForceFlush(ctx); err != nil {
+		hlog.CtxErrorf(ctx, "[Tracing] Error flushing spans: %v", err)
+	}
+}
+
+// Shutdown flushes pending spans and stops the exporter.
+func Shutdown(ctx context.Context) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(ctx); err != nil {
+		hlog.CtxErrorf(ctx, "[Tracing] Error shutting down tracer provider: %v", err)
+	}
+	provider = nil
+}
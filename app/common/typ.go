@@ -1,7 +1,17 @@
 package common
 
+import "github.com/tundrawork/stargate/app/common/apierror"
+
 type APIResponse struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    interface{}     `json:"data"`
+	Error   *APIErrorDetail `json:"error,omitempty"` // Set on errors rendered by RenderError
+}
+
+// APIErrorDetail is the machine-readable part of an error response.
+type APIErrorDetail struct {
+	Code      apierror.Code `json:"code"`
+	Retryable bool          `json:"retryable"`
+	RequestID string        `json:"requestId,omitempty"`
 }
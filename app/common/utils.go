@@ -1,6 +1,13 @@
 package common
 
-import "reflect"
+import (
+	"reflect"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/requestid"
+)
 
 // APIResponseSuccess constructs a typ.APIResponse of success.
 func APIResponseSuccess(data interface{}) APIResponse {
@@ -11,13 +18,20 @@ func APIResponseSuccess(data interface{}) APIResponse {
 	}
 }
 
-// APIResponseError constructs a typ.APIResponse of error.
-func APIResponseError(code int, message string) APIResponse {
-	return APIResponse{
-		Code:    code,
-		Message: message,
+// RenderError writes err as the response, with the HTTP status, code and retryability of the apierror.Error it maps to.
+// Only the message of the apierror.Error is shown, the cause stays in the logs.
+func RenderError(c *app.RequestContext, err error) {
+	apiErr := apierror.From(err)
+	c.JSON(apiErr.Status(), APIResponse{
+		Code:    apiErr.Status(),
+		Message: apiErr.Message,
 		Data:    nil,
-	}
+		Error: &APIErrorDetail{
+			Code:      apiErr.Code,
+			Retryable: apiErr.Retryable(),
+			RequestID: requestid.Get(c),
+		},
+	})
 }
 
 // ToPtr converts a value of any type to a pointer of that type.
@@ -29,3 +43,12 @@ func ToPtr[T any](v T) *T {
 	}
 	return &v
 }
+
+// FromPtr returns the value of a pointer, or the zero value if it is nil.
+func FromPtr[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
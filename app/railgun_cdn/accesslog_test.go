@@ -0,0 +1,57 @@
+package railgun_cdn
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/tundrawork/stargate/app/common/accesslog"
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/config"
+)
+
+func TestReportEventAppliesPrivacyBeforeAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.jsonl")
+	accesslog.Init(config.AccessLog{Path: path})
+	tenant := &TenantBusinessData{
+		AppID: "app-a",
+		Privacy: config.AnalyticsPrivacy{
+			IPv4Prefix:      24,
+			HonorDoNotTrack: true,
+			StripUserAgent:  true,
+			OptOutPaths:     []string{"/private/"},
+		},
+	}
+	report := func(objectPath string, doNotTrack bool) {
+		c := app.NewContext(0)
+		if doNotTrack {
+			c.Request.Header.Set("DNT", "1")
+		}
+		reportEvent(context.Background(), c, tenant, analytics.Event{
+			AppID:     "app-a",
+			ClientIP:  "203.0.113.42",
+			UserAgent: "curl/8.0",
+			EventName: objectPath,
+		})
+	}
+	report("/public/a.png", false)
+	report("/public/b.png", true)
+	report("/private/c.png", false)
+	accesslog.Shutdown(context.Background())
+
+	var records []accesslog.Record
+	if err := accesslog.ReadFile(path, func(record accesslog.Record) error {
+		records = append(records, record)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want only the tracked request: %+v", len(records), records)
+	}
+	if record := records[0]; record.EventName != "/public/a.png" || record.ClientIP != "203.0.113.0" || record.UserAgent != "" {
+		t.Errorf("record = %+v, want the masked IP and no user agent", record)
+	}
+}
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
 	"time"
 
+	"github.com/tundrawork/stargate/app/common/apierror"
 	"github.com/tundrawork/stargate/config"
 )
 
@@ -19,13 +22,27 @@ func GetObjectPublicURL(appId, objectPath, sign string, timestamp int64) string
 	)
 }
 
+// defaultMaxTTL is the maximum lifespan of signed URLs in seconds if not configured.
+const defaultMaxTTL = 7 * 24 * 60 * 60
+
+// MaxTTL returns the maximum lifespan of signed URLs in seconds.
+func MaxTTL() int64 {
+	if config.Conf.Services.RailgunCDN.CDN.MaxTTL > 0 {
+		return config.Conf.Services.RailgunCDN.CDN.MaxTTL
+	}
+	return defaultMaxTTL
+}
+
 // SignObject gets the URL of an object using specific endpoint.
 func SignObject(objectKey string, ttl int64) (sign string, timestamp int64, expires int64, err error) {
 	if ttl <= 0 {
-		return "", -1, -1, fmt.Errorf("ttl must be a positive integer")
+		return "", -1, -1, apierror.New(apierror.CodeInvalidRequest, "ttl must be a positive integer")
+	}
+	if ttl > MaxTTL() {
+		return "", -1, -1, apierror.New(apierror.CodeInvalidRequest, fmt.Sprintf("ttl must not exceed %d seconds", MaxTTL()))
 	}
 	if len(objectKey) == 0 || objectKey[len(objectKey)-1] == '/' {
-		return "", -1, -1, fmt.Errorf("invalid object key")
+		return "", -1, -1, apierror.New(apierror.CodeInvalidRequest, "invalid object key")
 	}
 
 	expires = time.Now().Unix() + ttl
@@ -35,3 +52,15 @@ func SignObject(objectKey string, ttl int64) (sign string, timestamp int64, expi
 
 	return sign, timestamp, expires, nil
 }
+
+// SignIssuance signs the issue time of a signed URL, so it can be trusted when checking revocations.
+func SignIssuance(sign string, issuedAt int64) string {
+	mac := hmac.New(sha256.New, []byte(config.Conf.Services.RailgunCDN.CDN.PKey))
+	_, _ = fmt.Fprintf(mac, "%s%d", sign, issuedAt) // Never returns an error
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// VerifyIssuance checks if the issue time of a signed URL matches its issuance sign.
+func VerifyIssuance(sign string, issuedAt int64, issuanceSign string) bool {
+	return hmac.Equal([]byte(SignIssuance(sign, issuedAt)), []byte(issuanceSign))
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+// CDNCache refreshes the cache of the CDN edge nodes.
+type CDNCache interface {
+	// PurgeURLs purges the cache of the given URLs.
+	PurgeURLs(ctx context.Context, urls []string) (taskID string, err error)
+	// PurgePaths purges the cache of all URLs under the given directories.
+	PurgePaths(ctx context.Context, paths []string) (taskID string, err error)
+	// PushURLs prefetches the given URLs to the edge nodes.
+	PushURLs(ctx context.Context, urls []string) (taskID string, err error)
+}
+
+// tencentCDNCache implements CDNCache with the Tencent Cloud CDN API.
+type tencentCDNCache struct {
+	endpoint   string
+	secretID   string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// MockCDNCache implements CDNCache in memory, for local development and testing without a real CDN.
+type MockCDNCache struct {
+	mutex  sync.Mutex
+	nextID int
+	Tasks  []MockCDNCacheTask
+}
+
+type MockCDNCacheTask struct {
+	TaskID  string
+	Action  string
+	Targets []string
+}
+
+const (
+	tencentCDNAPIHost    = "cdn.tencentcloudapi.com"
+	tencentCDNAPIService = "cdn"
+	tencentCDNAPIVersion = "2018-06-06"
+)
+
+var (
+	cdnCache CDNCache
+)
+
+// InitCDNCache initializes the CDN cache client.
+// If mock is true, an in-memory mock is used instead of the Tencent Cloud CDN API.
+// It should be called once, typically during application startup.
+func InitCDNCache(endpoint, secretID, secretKey string, mock bool) {
+	if mock {
+		cdnCache = &MockCDNCache{}
+		hlog.Infof("[CDNCache] Initialized mock client")
+		return
+	}
+	if endpoint == "" {
+		endpoint = "https://" + tencentCDNAPIHost
+	}
+	cdnCache = &tencentCDNCache{
+		endpoint:  endpoint,
+		secretID:  secretID,
+		secretKey: secretKey,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// SetCDNCache replaces the CDN cache client, e.g. with a MockCDNCache whose tasks are inspected by tests.
+func SetCDNCache(cache CDNCache) {
+	cdnCache = cache
+}
+
+// PurgeURLs purges the CDN cache of the given URLs.
+func PurgeURLs(ctx context.Context, urls []string) (_ string, err error) {
+	ctx, call := startBackendCall(ctx, backendCDN, "PurgeURLs")
+	defer call.end(&err)
+	if cdnCache == nil {
+		return "", errors.New("cdn cache client is not initialized")
+	}
+	return cdnCache.PurgeURLs(ctx, urls)
+}
+
+// PurgePaths purges the CDN cache of all URLs under the given directories.
+func PurgePaths(ctx context.Context, paths []string) (_ string, err error) {
+	ctx, call := startBackendCall(ctx, backendCDN, "PurgePaths")
+	defer call.end(&err)
+	if cdnCache == nil {
+		return "", errors.New("cdn cache client is not initialized")
+	}
+	return cdnCache.PurgePaths(ctx, paths)
+}
+
+// PushURLs prefetches the given URLs to the CDN edge nodes.
+func PushURLs(ctx context.Context, urls []string) (_ string, err error) {
+	ctx, call := startBackendCall(ctx, backendCDN, "PushURLs")
+	defer call.end(&err)
+	if cdnCache == nil {
+		return "", errors.New("cdn cache client is not initialized")
+	}
+	return cdnCache.PushURLs(ctx, urls)
+}
+
+// GetObjectCDNURL gets the CDN URL of an object or a directory without signature, as used for cache operations.
+func GetObjectCDNURL(appId, objectPath string) string {
+	return fmt.Sprintf("%s/%s%s", config.Conf.Services.RailgunCDN.CDN.Endpoint, appId, objectPath)
+}
+
+func (t *tencentCDNCache) PurgeURLs(ctx context.Context, urls []string) (string, error) {
+	return t.call(ctx, "PurgeUrlsCache", map[string]interface{}{
+		"Urls": urls,
+	})
+}
+
+func (t *tencentCDNCache) PurgePaths(ctx context.Context, paths []string) (string, error) {
+	return t.call(ctx, "PurgePathCache", map[string]interface{}{
+		"Paths":     paths,
+		"FlushType": "flush", // "flush" purges changed resources only, "delete" purges all resources
+	})
+}
+
+func (t *tencentCDNCache) PushURLs(ctx context.Context, urls []string) (string, error) {
+	return t.call(ctx, "PushUrlsCache", map[string]interface{}{
+		"Urls": urls,
+	})
+}
+
+// call invokes an action of the Tencent Cloud CDN API, signed with TC3-HMAC-SHA256, and returns the task ID.
+func (t *tencentCDNCache) call(ctx context.Context, action string, params map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	host := req.URL.Host
+	now := time.Now().UTC()
+	req.Host = host
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", tencentCDNAPIVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("Authorization", t.authorization(host, payload, now))
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close() // Ignore error
+	}(resp.Body)
+
+	var result struct {
+		Response struct {
+			TaskId    string `json:"TaskId"`
+			RequestId string `json:"RequestId"`
+			Error     *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error"`
+		} `json:"Response"`
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("cdn api error: unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding cdn api response: %w", err)
+	}
+	if result.Response.Error != nil {
+		return "", fmt.Errorf("cdn api error: %s: %s", result.Response.Error.Code, result.Response.Error.Message)
+	}
+	if result.Response.TaskId == "" {
+		return "", errors.New("cdn api error: empty task id")
+	}
+	return result.Response.TaskId, nil
+}
+
+// authorization computes the TC3-HMAC-SHA256 authorization header of a request.
+func (t *tencentCDNCache) authorization(host string, payload []byte, now time.Time) string {
+	date := now.Format("2006-01-02")
+	hashedPayload := sha256.Sum256(payload)
+	canonicalRequest := fmt.Sprintf("POST\n/\n\ncontent-type:application/json; charset=utf-8\nhost:%s\n\ncontent-type;host\n%s",
+		host,
+		hex.EncodeToString(hashedPayload[:]),
+	)
+	hashedCanonicalRequest := sha256.Sum256([]byte(canonicalRequest))
+	credentialScope := fmt.Sprintf("%s/%s/tc3_request", date, tencentCDNAPIService)
+	stringToSign := fmt.Sprintf("TC3-HMAC-SHA256\n%d\n%s\n%s",
+		now.Unix(),
+		credentialScope,
+		hex.EncodeToString(hashedCanonicalRequest[:]),
+	)
+	secretDate := hmacSHA256([]byte("TC3"+t.secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentCDNAPIService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s",
+		t.secretID,
+		credentialScope,
+		signature,
+	)
+}
+
+func (m *MockCDNCache) PurgeURLs(ctx context.Context, urls []string) (string, error) {
+	return m.record(ctx, "PurgeUrlsCache", urls), nil
+}
+
+func (m *MockCDNCache) PurgePaths(ctx context.Context, paths []string) (string, error) {
+	return m.record(ctx, "PurgePathCache", paths), nil
+}
+
+func (m *MockCDNCache) PushURLs(ctx context.Context, urls []string) (string, error) {
+	return m.record(ctx, "PushUrlsCache", urls), nil
+}
+
+// record stores a task of the mock and returns its task ID.
+func (m *MockCDNCache) record(ctx context.Context, action string, targets []string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.nextID++
+	taskID := fmt.Sprintf("mock-%d", m.nextID)
+	m.Tasks = append(m.Tasks, MockCDNCacheTask{
+		TaskID:  taskID,
+		Action:  action,
+		Targets: append([]string(nil), targets...),
+	})
+	hlog.CtxInfof(ctx, "[CDNCache] Mock Action=%s TaskID=%s Targets=%v", action, taskID, targets)
+	return taskID
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
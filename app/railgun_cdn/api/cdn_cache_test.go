@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTencentCDNCacheCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		taskID  string
+		wantErr string
+	}{
+		{"ok", http.StatusOK, `{"Response":{"TaskId":"task-1","RequestId":"req-1"}}`, "task-1", ""},
+		{"api error", http.StatusOK, `{"Response":{"Error":{"Code":"AuthFailure","Message":"denied"}}}`, "", "AuthFailure"},
+		{"empty task id", http.StatusOK, `{}`, "", "empty task id"},
+		{"bad status", http.StatusBadGateway, `{}`, "", "unexpected status 502"},
+		{"not json", http.StatusOK, `<html></html>`, "", "error decoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if action := r.Header.Get("X-TC-Action"); action != "PurgeUrlsCache" {
+					t.Errorf("X-TC-Action = %q, want PurgeUrlsCache", action)
+				}
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=id/") {
+					t.Errorf("Authorization = %q, want a TC3 signature of the secret ID", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			cache := &tencentCDNCache{endpoint: server.URL, secretID: "id", secretKey: "key", httpClient: server.Client()}
+
+			taskID, err := cache.PurgeURLs(context.Background(), []string{"https://cdn.example.com/app-a/a.png"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if taskID != tt.taskID {
+				t.Errorf("taskID = %q, want %q", taskID, tt.taskID)
+			}
+		})
+	}
+}
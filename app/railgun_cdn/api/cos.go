@@ -1,25 +1,57 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/mozillazg/go-httpheader"
 	"github.com/tencentyun/cos-go-sdk-v5"
 	"github.com/tencentyun/cos-go-sdk-v5/debug"
 
 	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/logging"
 )
 
 type ObjectMetadata struct {
-	ContentType   *string `json:"content-type"`
-	ContentLength *int64  `json:"content-length"`
-	ETag          *string `json:"etag"`
-	LastModified  *string `json:"last-modified"`
-	CRC64         *string `json:"crc64"`
+	ContentType        *string           `json:"content-type"`
+	ContentLength      *int64            `json:"content-length"`
+	ETag               *string           `json:"etag"`
+	LastModified       *string           `json:"last-modified"`
+	CRC64              *string           `json:"crc64"`
+	CacheControl       *string           `json:"cache-control,omitempty"`
+	ContentDisposition *string           `json:"content-disposition,omitempty"`
+	ContentEncoding    *string           `json:"content-encoding,omitempty"`
+	ContentLanguage    *string           `json:"content-language,omitempty"`
+	Meta               map[string]string `json:"meta,omitempty"`
+}
+
+// ObjectHeaders holds the HTTP headers and user metadata stored with an object.
+type ObjectHeaders struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	Meta               map[string]string // User metadata, keys without the "x-cos-meta-" prefix
 }
 
 type ObjectKey string
@@ -33,8 +65,29 @@ type PutObjectResponse struct {
 
 type HeadObjectResponse ObjectMetadata
 
+// Checksums holds the expected checksums of an uploaded object, empty fields are not checked.
+type Checksums struct {
+	MD5    []byte // Raw MD5 digest
+	SHA256 []byte // Raw SHA-256 digest
+	CRC64  string // Decimal CRC-64/ECMA value, as returned by COS
+}
+
+const (
+	cosMetaPrefix = "X-Cos-Meta-"
+	// reservedPrefix is the prefix of the keys of the bucket used by Stargate itself, never in a tenant's root path.
+	reservedPrefix = ".stargate/"
+	// temporaryUploadPrefix is the prefix of uploads verified before being copied onto their object. Uploads
+	// interrupted by a crash are left behind, a lifecycle rule of the bucket can expire them.
+	temporaryUploadPrefix = reservedPrefix + "uploads/"
+	// listPageSize is the number of objects per page when listing objects, the maximum allowed by COS.
+	listPageSize = 1000
+	// headConcurrency is the maximum number of concurrent HEAD requests when listing objects with metadata.
+	headConcurrency = 16
+)
+
 var (
-	cosClient *cos.Client
+	cosClient     *cos.Client
+	cosHTTPClient *http.Client // The HTTP client of cosClient, for the requests the SDK cannot send
 )
 
 // InitCosClient initializes the COS client.
@@ -45,70 +98,191 @@ func InitCosClient(bucket, region, secretID, secretKey string) {
 	if err != nil {
 		return
 	}
-	baseURL := &cos.BaseURL{BucketURL: bucketURL}
-	cosClient = cos.NewClient(baseURL, &http.Client{
+	SetCosClient(bucketURL, &http.Client{
 		Transport: &cos.AuthorizationTransport{
 			SecretID:  secretID,
 			SecretKey: secretKey,
+			// Dumps requests and responses at debug level, through the logger redacting their signatures
 			Transport: &debug.DebugRequestTransport{
 				RequestHeader:  true,
 				RequestBody:    false,
 				ResponseHeader: true,
 				ResponseBody:   false,
+				Writer:         logging.DebugWriter("[COS]"),
 			},
 		},
 	})
 }
 
-// GetBucket lists objects in a COS bucket.
-func GetBucket(ctx context.Context, prefix string) (ListObjectsResponse, error) {
-	opt := &cos.BucketGetOptions{
-		Prefix: prefix,
+// SetCosClient points the COS client to a bucket, e.g. to a costest.Bucket in tests.
+func SetCosClient(bucketURL *url.URL, httpClient *http.Client) {
+	cosClient = cos.NewClient(&cos.BaseURL{BucketURL: bucketURL}, httpClient)
+	cosHTTPClient = httpClient
+}
+
+// CheckRootPath returns an error if rootPath cannot hold the objects of a tenant: an empty root path would give the
+// tenant the whole bucket, and one overlapping the keys reserved by Stargate would expose its temporary uploads.
+func CheckRootPath(rootPath string) error {
+	if rootPath == "" {
+		return errors.New("root path is empty")
 	}
-	resp, _, err := cosClient.Bucket.Get(ctx, opt)
-	if err != nil {
-		return ListObjectsResponse{}, err
+	if strings.HasPrefix(rootPath+"/", reservedPrefix) || strings.HasPrefix(reservedPrefix, rootPath+"/") {
+		return fmt.Errorf("root path overlaps the reserved prefix %s", reservedPrefix)
 	}
-	if resp == nil {
-		return ListObjectsResponse{}, errors.New("empty response from storage")
+	return nil
+}
+
+// CheckBucket checks that the COS bucket is reachable with the configured credentials.
+func CheckBucket(ctx context.Context) (err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "HeadBucket")
+	defer call.end(&err)
+	_, err = cosClient.Bucket.Head(ctx)
+	return err
+}
+
+// GetBucket lists the objects under rootPath whose path starts with prefix, following the pages of the listing until
+// it is complete. The objects are keyed by their path under rootPath.
+// If withMetadata is true, the full metadata of every object is retrieved as well.
+func GetBucket(ctx context.Context, rootPath, prefix string, withMetadata bool) (_ ListObjectsResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "GetBucket")
+	defer call.end(&err)
+	opt := &cos.BucketGetOptions{
+		Prefix:  rootPath + prefix,
+		MaxKeys: listPageSize,
 	}
 	res := make(ListObjectsResponse)
-	for _, obj := range resp.Contents {
-		// trim prefix from key
-		obj.Key = obj.Key[len(prefix):]
-		// trim quotes from ETag
-		obj.ETag = obj.ETag[1 : len(obj.ETag)-1]
-		res[ObjectKey(obj.Key)] = ObjectMetadata{
-			ContentType:   nil,
-			ContentLength: common.ToPtr(obj.Size),
-			ETag:          common.ToPtr(obj.ETag),
-			LastModified:  common.ToPtr(obj.LastModified),
-			CRC64:         nil,
+	for {
+		resp, _, err := cosClient.Bucket.Get(ctx, opt)
+		if err != nil {
+			return ListObjectsResponse{}, err
 		}
+		if resp == nil {
+			return ListObjectsResponse{}, errors.New("empty response from storage")
+		}
+		for _, obj := range resp.Contents {
+			res[ObjectKey(obj.Key[len(rootPath):])] = ObjectMetadata{
+				ContentType:   nil,
+				ContentLength: common.ToPtr(obj.Size),
+				ETag:          common.ToPtr(strings.Trim(obj.ETag, `"`)),
+				LastModified:  common.ToPtr(obj.LastModified),
+				CRC64:         nil,
+			}
+		}
+		if !resp.IsTruncated {
+			break
+		}
+		// NextMarker is only returned with a delimiter, the last key of the page is the marker otherwise
+		marker := resp.NextMarker
+		if marker == "" && len(resp.Contents) > 0 {
+			marker = resp.Contents[len(resp.Contents)-1].Key
+		}
+		if marker == "" || marker == opt.Marker {
+			return ListObjectsResponse{}, errors.New("truncated listing without marker from storage")
+		}
+		opt.Marker = marker
 	}
-	return res, err
+	if withMetadata {
+		if err := fillMetadata(ctx, rootPath, res); err != nil {
+			return ListObjectsResponse{}, err
+		}
+	}
+	return res, nil
 }
 
-// PutObject puts a streamable object to COS.
-func PutObject(ctx context.Context, objectKey string, dataStream io.Reader, contentType string, ttl int64) (PutObjectResponse, error) {
-	if contentType == "" {
-		contentType = "application/octet-stream"
+// fillMetadata replaces the listed metadata of objects with the full metadata from HEAD requests.
+func fillMetadata(ctx context.Context, rootPath string, objects ListObjectsResponse) error {
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	// The keys are collected first, since ranging over objects would race with the writes of the goroutines.
+	keys := make([]ObjectKey, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
 	}
-	headerOptions := &cos.ObjectPutHeaderOptions{
-		ContentType: contentType,
-	}
-	if ttl > 0 {
-		timestamp := time.Now().Unix() + ttl
-		headerOptions.Expires = time.Unix(timestamp, 0).Format(time.RFC1123)
+	semaphore := make(chan struct{}, headConcurrency)
+	for _, key := range keys {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(key ObjectKey) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			metadata, err := HeadObject(ctx, rootPath+string(key))
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			objects[key] = ObjectMetadata(metadata)
+		}(key)
 	}
+	wg.Wait()
+	return firstErr
+}
+
+// PutObject puts a streamable object to COS, ErrChecksumMismatch is returned if the data does not match checksums.
+// Without checksums, or with only an MD5 digest, which is sent to COS to reject mismatching data before storing it,
+// the data is put to the object directly. Otherwise, the data is uploaded to a temporary key and copied onto the
+// object once its checksums, computed while streaming, are verified against the expected ones and the storage, so
+// that mismatching data never replaces the object. Such uploads are limited to the 5 GB of a single copy.
+func PutObject(ctx context.Context, objectKey string, dataStream io.Reader, headers ObjectHeaders, checksums Checksums, ttl int64) (_ PutObjectResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "PutObject")
+	defer call.end(&err)
 	opt := &cos.ObjectPutOptions{
-		ObjectPutHeaderOptions: headerOptions,
+		ObjectPutHeaderOptions: putHeaderOptions(headers, ttl),
 		ACLHeaderOptions: &cos.ACLHeaderOptions{
 			XCosACL: "private", // "private" | "public-read" | "public-read-write" | "authenticated-read"
 		},
 	}
-	resp, err := cosClient.Object.Put(ctx, objectKey, dataStream, opt)
+	if checksums.SHA256 == nil && checksums.CRC64 == "" {
+		if checksums.MD5 != nil {
+			opt.ContentMD5 = base64.StdEncoding.EncodeToString(checksums.MD5)
+		}
+		res, err := putObject(ctx, objectKey, dataStream, checksums, opt)
+		if errors.Is(err, ErrChecksumMismatch) {
+			// The storage accepted the data, which already replaced the object, but stored something else than
+			// what was read: this is not the client's fault, and deleting the object would lose both versions.
+			return PutObjectResponse{}, apierror.Wrap(apierror.CodeInternal, "stored object does not match the uploaded data", err)
+		}
+		return res, err
+	}
+
+	uploadKey, err := temporaryUploadKey()
+	if err != nil {
+		return PutObjectResponse{}, err
+	}
+	defer func() {
+		if err := DeleteObject(context.WithoutCancel(ctx), uploadKey); err != nil {
+			hlog.CtxWarnf(ctx, "[COS] Error deleting temporary upload %s: %v", uploadKey, err)
+		}
+	}()
+	if _, err := putObject(ctx, uploadKey, dataStream, checksums, opt); err != nil {
+		return PutObjectResponse{}, err
+	}
+	resp, err := copyObject(ctx, uploadKey, objectKey, &cos.ObjectCopyOptions{
+		ObjectCopyHeaderOptions: &cos.ObjectCopyHeaderOptions{XCosMetadataDirective: "Copy"},
+		ACLHeaderOptions:        opt.ACLHeaderOptions,
+	})
+	if err != nil {
+		return PutObjectResponse{}, err
+	}
+	return PutObjectResponse{ETag: resp.ETag, CRC64: resp.CRC64}, nil
+}
+
+// putObject streams data to objectKey and verifies its checksums, ErrChecksumMismatch is returned if they do not
+// match. The mismatching data is left in place, to be deleted by the caller if it is not the object itself.
+func putObject(ctx context.Context, objectKey string, dataStream io.Reader, checksums Checksums, opt *cos.ObjectPutOptions) (PutObjectResponse, error) {
+	sums := newChecksummer()
+	resp, err := cosClient.Object.Put(ctx, objectKey, sums.tee(dataStream), opt)
 	if err != nil {
+		// The SDK verifies the CRC-64 of the stored data as well, failing the request after the data was stored
+		if resp != nil && resp.StatusCode/100 == 2 {
+			return PutObjectResponse{}, errors.Join(ErrChecksumMismatch, err)
+		}
 		return PutObjectResponse{}, err
 	}
 	if resp == nil {
@@ -118,11 +292,75 @@ func PutObject(ctx context.Context, objectKey string, dataStream io.Reader, cont
 		ETag:  resp.Header.Get("ETag"),
 		CRC64: resp.Header.Get("x-cos-hash-crc64ecma"),
 	}
-	return res, err
+	if !sums.matches(checksums, res.CRC64) {
+		return PutObjectResponse{}, ErrChecksumMismatch
+	}
+	return res, nil
+}
+
+// temporaryUploadKey returns a new key for uploads verified before being copied onto their object.
+// It is outside the root paths of tenants, as checked by CheckRootPath, so that it is never listed nor served.
+func temporaryUploadKey() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return temporaryUploadPrefix + hex.EncodeToString(id), nil
+}
+
+// putHeaderOptions builds the COS headers of an uploaded object, expiring it after ttl seconds if positive.
+func putHeaderOptions(headers ObjectHeaders, ttl int64) *cos.ObjectPutHeaderOptions {
+	if headers.ContentType == "" {
+		headers.ContentType = "application/octet-stream"
+	}
+	headerOptions := &cos.ObjectPutHeaderOptions{
+		ContentType:        headers.ContentType,
+		CacheControl:       headers.CacheControl,
+		ContentDisposition: headers.ContentDisposition,
+		ContentEncoding:    headers.ContentEncoding,
+		ContentLanguage:    headers.ContentLanguage,
+		XCosMetaXXX:        metaHeader(headers.Meta),
+	}
+	if ttl > 0 {
+		timestamp := time.Now().Unix() + ttl
+		headerOptions.Expires = time.Unix(timestamp, 0).Format(time.RFC1123)
+	}
+	return headerOptions
+}
+
+// checksummer computes the checksums of uploaded data while it is streamed to COS.
+type checksummer struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc64  hash.Hash64
+}
+
+func newChecksummer() *checksummer {
+	return &checksummer{
+		md5:    md5.New(),
+		sha256: sha256.New(),
+		crc64:  crc64.New(crc64.MakeTable(crc64.ECMA)),
+	}
+}
+
+// tee returns a reader of dataStream that computes the checksums of the data read.
+func (s *checksummer) tee(dataStream io.Reader) io.Reader {
+	return io.TeeReader(dataStream, io.MultiWriter(s.md5, s.sha256, s.crc64))
+}
+
+// matches reports whether the data read matches the expected checksums and the CRC-64 computed by COS, if any.
+func (s *checksummer) matches(checksums Checksums, storedCRC64 string) bool {
+	crc64Sum := strconv.FormatUint(s.crc64.Sum64(), 10)
+	return (checksums.MD5 == nil || bytes.Equal(checksums.MD5, s.md5.Sum(nil))) &&
+		(checksums.SHA256 == nil || bytes.Equal(checksums.SHA256, s.sha256.Sum(nil))) &&
+		(checksums.CRC64 == "" || checksums.CRC64 == crc64Sum) &&
+		(storedCRC64 == "" || storedCRC64 == crc64Sum)
 }
 
 // HeadObject retrieves the metadata of an object from COS.
-func HeadObject(ctx context.Context, objectKey string) (HeadObjectResponse, error) {
+func HeadObject(ctx context.Context, objectKey string) (_ HeadObjectResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "HeadObject")
+	defer call.end(&err)
 	resp, err := cosClient.Object.Head(ctx, objectKey, nil)
 	if err != nil {
 		return HeadObjectResponse{}, err
@@ -130,21 +368,183 @@ func HeadObject(ctx context.Context, objectKey string) (HeadObjectResponse, erro
 	if resp == nil {
 		return HeadObjectResponse{}, errors.New("empty response from storage")
 	}
-	// trim quotes from ETag
-	eTag := resp.Header.Get("ETag")
-	eTag = eTag[1 : len(eTag)-1]
-	res := HeadObjectResponse{
-		ContentType:   common.ToPtr(resp.Header.Get("Content-Type")),
-		ContentLength: common.ToPtr(resp.ContentLength),
-		ETag:          common.ToPtr(eTag),
-		LastModified:  common.ToPtr(resp.Header.Get("Last-Modified")),
-		CRC64:         common.ToPtr(resp.Header.Get("x-cos-hash-crc64ecma")),
+	return objectMetadataFromResponse(resp), nil
+}
+
+// GetObject retrieves an object with its metadata from COS, the caller must close the returned body.
+// The backend call ends when the response headers are received, streaming the body is not part of it.
+func GetObject(ctx context.Context, objectKey string) (_ io.ReadCloser, _ HeadObjectResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "GetObject")
+	defer call.end(&err)
+	resp, err := cosClient.Object.Get(ctx, objectKey, nil)
+	if err != nil {
+		return nil, HeadObjectResponse{}, err
+	}
+	if resp == nil {
+		return nil, HeadObjectResponse{}, errors.New("empty response from storage")
+	}
+	return resp.Body, objectMetadataFromResponse(resp), nil
+}
+
+// objectMetadataFromResponse builds the metadata of an object from the headers of a HEAD or GET response.
+func objectMetadataFromResponse(resp *cos.Response) HeadObjectResponse {
+	return HeadObjectResponse{
+		ContentType:        common.ToPtr(resp.Header.Get("Content-Type")),
+		ContentLength:      common.ToPtr(resp.ContentLength),
+		ETag:               common.ToPtr(strings.Trim(resp.Header.Get("ETag"), `"`)),
+		LastModified:       common.ToPtr(resp.Header.Get("Last-Modified")),
+		CRC64:              common.ToPtr(resp.Header.Get("x-cos-hash-crc64ecma")),
+		CacheControl:       common.ToPtr(resp.Header.Get("Cache-Control")),
+		ContentDisposition: common.ToPtr(resp.Header.Get("Content-Disposition")),
+		ContentEncoding:    common.ToPtr(resp.Header.Get("Content-Encoding")),
+		ContentLanguage:    common.ToPtr(resp.Header.Get("Content-Language")),
+		Meta:               metaFromHeader(resp.Header),
+	}
+}
+
+// UpdateObjectMetadata updates the headers and user metadata of an object without re-uploading it.
+// Empty fields of headers keep their current values, and user metadata is merged into the current one.
+func UpdateObjectMetadata(ctx context.Context, objectKey string, headers ObjectHeaders) (_ PutObjectResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "UpdateObjectMetadata")
+	defer call.end(&err)
+	head, err := cosClient.Object.Head(ctx, objectKey, nil)
+	if err != nil {
+		return PutObjectResponse{}, err
+	}
+	if head == nil {
+		return PutObjectResponse{}, errors.New("empty response from storage")
+	}
+	// COS replaces all metadata when copying an object onto itself, so the current values must be carried over.
+	meta := metaFromHeader(head.Header)
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+	for key, value := range headers.Meta {
+		meta[key] = value
+	}
+	headerOptions := &cos.ObjectCopyHeaderOptions{
+		XCosMetadataDirective: "Replaced",
+		ContentType:           valueOr(headers.ContentType, head.Header.Get("Content-Type")),
+		CacheControl:          valueOr(headers.CacheControl, head.Header.Get("Cache-Control")),
+		ContentDisposition:    valueOr(headers.ContentDisposition, head.Header.Get("Content-Disposition")),
+		ContentEncoding:       valueOr(headers.ContentEncoding, head.Header.Get("Content-Encoding")),
+		ContentLanguage:       valueOr(headers.ContentLanguage, head.Header.Get("Content-Language")),
+		Expires:               head.Header.Get("Expires"),
+		XCosMetaXXX:           metaHeader(meta),
+		// Fails the copy if the object changed since the HEAD, whose metadata would be lost
+		XCosCopySourceIfMatch: head.Header.Get("ETag"),
+	}
+	resp, err := copyObject(ctx, objectKey, objectKey, &cos.ObjectCopyOptions{
+		ObjectCopyHeaderOptions: headerOptions,
+		ACLHeaderOptions: &cos.ACLHeaderOptions{
+			XCosACL: "private", // Copying resets the ACL to the bucket's default otherwise
+		},
+	})
+	var cosErr *cos.ErrorResponse
+	if errors.As(err, &cosErr) && cosErr.Response.StatusCode == http.StatusPreconditionFailed {
+		return PutObjectResponse{}, apierror.Wrap(apierror.CodeConflict, "object modified during the metadata update", err)
+	}
+	if err != nil {
+		return PutObjectResponse{}, err
+	}
+	if resp == nil {
+		return PutObjectResponse{}, errors.New("empty response from storage")
+	}
+	res := PutObjectResponse{
+		ETag:  resp.ETag,
+		CRC64: resp.CRC64,
 	}
 	return res, err
 }
 
+// copyObject copies an object of the bucket to objectKey with the given options.
+// The Copy method of the SDK reads a '?' in the source key as the start of a version ID, and escaping the key
+// beforehand escapes it twice, so the request is sent here, with the copy source escaped segment by segment.
+func copyObject(ctx context.Context, sourceKey, objectKey string, opt *cos.ObjectCopyOptions) (*cos.ObjectCopyResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, cosClient.BaseURL.BucketURL.String()+"/"+escapeObjectKey(objectKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, options := range []any{opt.ObjectCopyHeaderOptions, opt.ACLHeaderOptions} {
+		header, err := httpheader.Header(options)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+	}
+	req.Header.Set("x-cos-copy-source", cosClient.BaseURL.BucketURL.Host+"/"+escapeObjectKey(sourceKey))
+	resp, err := cosHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close() // Ignore error
+	}(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// COS may fail a copy after sending the status, with an error as body
+	if resp.StatusCode/100 == 2 {
+		var result cos.ObjectCopyResult
+		if err := xml.Unmarshal(data, &result); err == nil {
+			return &result, nil
+		}
+	}
+	errResp := &cos.ErrorResponse{Response: resp}
+	_ = xml.Unmarshal(data, errResp) // The status is enough to map the error otherwise
+	return nil, errResp
+}
+
+// escapeObjectKey escapes every segment of an object key for use in a URL path.
+func escapeObjectKey(objectKey string) string {
+	segments := strings.Split(objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = cos.EncodeURIComponent(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// metaHeader converts user metadata to x-cos-meta-* headers.
+func metaHeader(meta map[string]string) *http.Header {
+	if len(meta) == 0 {
+		return nil
+	}
+	header := make(http.Header, len(meta))
+	for key, value := range meta {
+		header.Set(cosMetaPrefix+key, value)
+	}
+	return &header
+}
+
+// metaFromHeader extracts user metadata from x-cos-meta-* headers.
+func metaFromHeader(header http.Header) map[string]string {
+	var meta map[string]string
+	for key := range header {
+		if strings.HasPrefix(key, cosMetaPrefix) {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			meta[strings.ToLower(key[len(cosMetaPrefix):])] = header.Get(key)
+		}
+	}
+	return meta
+}
+
+// valueOr returns value if it is not empty, otherwise fallback.
+func valueOr(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
 // DeleteObject deletes an object from COS.
-func DeleteObject(ctx context.Context, objectKey string) error {
-	_, err := cosClient.Object.Delete(ctx, objectKey)
+func DeleteObject(ctx context.Context, objectKey string) (err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "DeleteObject")
+	defer call.end(&err)
+	_, err = cosClient.Object.Delete(ctx, objectKey)
 	return err
 }
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api/costest"
+)
+
+// newFakeBucket points the COS client to a bucket listing keys in pages of pageSize, without NextMarker.
+func newFakeBucket(t *testing.T, keys []string, pageSize int) *[]string {
+	t.Helper()
+	var markers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		marker := query.Get("marker")
+		markers = append(markers, marker)
+		start := 0
+		for start < len(keys) && keys[start] <= marker {
+			start++
+		}
+		end := min(start+pageSize, len(keys))
+		var contents strings.Builder
+		for _, key := range keys[start:end] {
+			_, _ = fmt.Fprintf(&contents, `<Contents><Key>%s</Key><ETag>"etag-%s"</ETag><Size>1</Size></Contents>`, key, key)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = fmt.Fprintf(w, `<ListBucketResult><Prefix>%s</Prefix><Marker>%s</Marker><IsTruncated>%t</IsTruncated>%s</ListBucketResult>`,
+			query.Get("prefix"), marker, end < len(keys), contents.String())
+	}))
+	t.Cleanup(server.Close)
+	bucketURL, _ := url.Parse(server.URL)
+	previous := cosClient
+	cosClient = cos.NewClient(&cos.BaseURL{BucketURL: bucketURL}, server.Client())
+	t.Cleanup(func() { cosClient = previous })
+	return &markers
+}
+
+func TestGetBucketPaginates(t *testing.T) {
+	markers := newFakeBucket(t, []string{"app-a/1", "app-a/2", "app-a/3", "app-a/4", "app-a/5"}, 2)
+
+	objects, err := GetBucket(context.Background(), "app-a", "", false)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	var keys []string
+	for key := range objects {
+		keys = append(keys, string(key))
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"/1", "/2", "/3", "/4", "/5"}) {
+		t.Errorf("listed %v, want all 5 objects", keys)
+	}
+	if etag := *objects["/5"].ETag; etag != "etag-app-a/5" {
+		t.Errorf("ETag = %q, want it without quotes", etag)
+	}
+	if !slices.Equal(*markers, []string{"", "app-a/2", "app-a/4"}) {
+		t.Errorf("requested pages after markers %q", *markers)
+	}
+}
+
+// useTestBucket points the COS client to an in-memory bucket.
+func useTestBucket(t *testing.T) *costest.Bucket {
+	t.Helper()
+	bucket := costest.NewBucket()
+	t.Cleanup(bucket.Close)
+	previous, previousHTTPClient := cosClient, cosHTTPClient
+	SetCosClient(bucket.URL(), bucket.Client())
+	t.Cleanup(func() { cosClient, cosHTTPClient = previous, previousHTTPClient })
+	return bucket
+}
+
+func TestUpdateObjectMetadataEscapesKey(t *testing.T) {
+	useTestBucket(t)
+	ctx := context.Background()
+	for _, key := range []string{"app-a/plain.png", "app-a/what?versionId=1.png", "app-a/100% sure #1.png"} {
+		if _, err := PutObject(ctx, key, strings.NewReader("data"), ObjectHeaders{ContentType: "image/png"}, Checksums{}, 0); err != nil {
+			t.Fatalf("PutObject(%q): %v", key, err)
+		}
+		if _, err := UpdateObjectMetadata(ctx, key, ObjectHeaders{CacheControl: "no-cache", Meta: map[string]string{"owner": "ci"}}); err != nil {
+			t.Fatalf("UpdateObjectMetadata(%q): %v", key, err)
+		}
+		head, err := HeadObject(ctx, key)
+		if err != nil {
+			t.Fatalf("HeadObject(%q): %v", key, err)
+		}
+		if *head.ContentType != "image/png" || *head.CacheControl != "no-cache" || head.Meta["owner"] != "ci" {
+			t.Errorf("metadata of %q after update: %+v", key, head)
+		}
+	}
+}
+
+func TestUpdateObjectMetadataConflictsWithConcurrentWrites(t *testing.T) {
+	bucket := useTestBucket(t)
+	ctx := context.Background()
+	const key = "app-a/a.png"
+	if _, err := PutObject(ctx, key, strings.NewReader("data"), ObjectHeaders{ContentType: "image/png"}, Checksums{}, 0); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	var ifMatch string
+	bucket.Fail = func(r *http.Request, _ []byte) *costest.Failure {
+		if r.Header.Get("x-cos-copy-source") == "" {
+			return nil
+		}
+		ifMatch = r.Header.Get("x-cos-copy-source-If-Match")
+		// The object is replaced between the HEAD and the copy of the update
+		if _, err := PutObject(ctx, key, strings.NewReader("changed"), ObjectHeaders{ContentType: "text/plain"}, Checksums{}, 0); err != nil {
+			t.Errorf("PutObject: %v", err)
+		}
+		return nil
+	}
+
+	_, err := UpdateObjectMetadata(ctx, key, ObjectHeaders{CacheControl: "no-cache"})
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.CodeConflict {
+		t.Fatalf("UpdateObjectMetadata = %v, want a %s error", err, apierror.CodeConflict)
+	}
+	if want := fmt.Sprintf(`"%x"`, md5.Sum([]byte("data"))); ifMatch != want {
+		t.Errorf("copy sent with If-Match %q, want the ETag %s of the HEAD", ifMatch, want)
+	}
+	head, err := HeadObject(ctx, key)
+	if err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if data, _ := bucket.Object(key); string(data) != "changed" || *head.ContentType != "text/plain" || head.CacheControl != nil {
+		t.Errorf("object %q with metadata %+v, want the concurrent write kept as is", data, head)
+	}
+}
+
+func TestPutObjectKeepsObjectOnChecksumMismatch(t *testing.T) {
+	bucket := useTestBucket(t)
+	ctx := context.Background()
+	if _, err := PutObject(ctx, "app-a/a.txt", strings.NewReader("good"), ObjectHeaders{}, Checksums{}, 0); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	wrongMD5 := md5.Sum([]byte("other"))
+	wrongSHA256 := sha256.Sum256([]byte("other"))
+	for name, checksums := range map[string]Checksums{
+		"md5 verified by the storage":  {MD5: wrongMD5[:]},
+		"sha256 verified after upload": {SHA256: wrongSHA256[:]},
+		"crc64 verified after upload":  {CRC64: "1"},
+	} {
+		_, err := PutObject(ctx, "app-a/a.txt", strings.NewReader("bad"), ObjectHeaders{}, checksums, 0)
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != apierror.CodeChecksumMismatch {
+			t.Errorf("%s: PutObject: %v, want a checksum mismatch", name, err)
+		}
+	}
+	if data, _ := bucket.Object("app-a/a.txt"); string(data) != "good" {
+		t.Errorf("object = %q, want the previous version", data)
+	}
+	if keys := bucket.Keys(); !slices.Equal(keys, []string{"app-a/a.txt"}) {
+		t.Errorf("bucket holds %v, want no temporary upload left", keys)
+	}
+
+	goodMD5 := md5.Sum([]byte("new"))
+	if _, err := PutObject(ctx, "app-a/a.txt", strings.NewReader("new"), ObjectHeaders{}, Checksums{MD5: goodMD5[:]}, 0); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if data, _ := bucket.Object("app-a/a.txt"); string(data) != "new" {
+		t.Errorf("object = %q, want the new version", data)
+	}
+}
+
+func TestPutObjectWithoutChecksumsPutsDirectly(t *testing.T) {
+	bucket := useTestBucket(t)
+	var requests []string
+	bucket.Fail = func(r *http.Request, _ []byte) *costest.Failure {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		return nil
+	}
+	if _, err := PutObject(context.Background(), "app-a/a.txt", strings.NewReader("data"), ObjectHeaders{}, Checksums{}, 0); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if !slices.Equal(requests, []string{"PUT /app-a/a.txt"}) {
+		t.Errorf("requests = %v, want a single put of the object", requests)
+	}
+}
+
+// roundTripperFunc is an http.RoundTripper calling itself.
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestPutObjectKeepsStoredObjectOnStorageCRC64Mismatch(t *testing.T) {
+	bucket := useTestBucket(t)
+	// The storage reports a CRC-64 of other data than what was sent
+	SetCosClient(bucket.URL(), &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err == nil && r.Method == http.MethodPut {
+			resp.Header.Set("x-cos-hash-crc64ecma", "1")
+		}
+		return resp, err
+	})})
+
+	sum := md5.Sum([]byte("new"))
+	_, err := PutObject(context.Background(), "app-a/a.txt", strings.NewReader("new"), ObjectHeaders{}, Checksums{MD5: sum[:]}, 0)
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.CodeInternal {
+		t.Errorf("PutObject: %v, want an internal error, the client sent the right data", err)
+	}
+	if data, ok := bucket.Object("app-a/a.txt"); !ok || string(data) != "new" {
+		t.Errorf("object = %q, %t, want it kept rather than deleted", data, ok)
+	}
+}
+
+func TestCheckRootPath(t *testing.T) {
+	for rootPath, valid := range map[string]bool{
+		"app-a":             true,
+		"tenants/app-a":     true,
+		".stargate-app":     true,
+		"":                  false,
+		".stargate":         false,
+		".stargate/uploads": false,
+	} {
+		if err := CheckRootPath(rootPath); (err == nil) != valid {
+			t.Errorf("CheckRootPath(%q) = %v, want valid %t", rootPath, err, valid)
+		}
+	}
+}
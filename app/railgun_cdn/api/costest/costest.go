@@ -0,0 +1,376 @@
+// Package costest serves an in-memory COS bucket over HTTP, to test the code calling COS without a real bucket.
+// It implements the subset of the COS API used by Stargate: listing, simple and multipart uploads, HEAD, GET,
+// DELETE and copies onto the same bucket.
+package costest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failure is an error response of the bucket.
+type Failure struct {
+	Status int
+	Code   string // COS error code, e.g. "SlowDown"
+}
+
+// Bucket is an in-memory COS bucket served by an httptest.Server. It must be closed with Close.
+type Bucket struct {
+	*httptest.Server
+
+	// Fail, if set, is called with every request and its body before it is handled,
+	// and fails the request if it returns a failure.
+	Fail func(r *http.Request, body []byte) *Failure
+
+	mutex   sync.Mutex
+	objects map[string]*object
+	uploads map[string]*upload
+	nextID  int
+}
+
+type object struct {
+	data     []byte
+	header   http.Header // Stored headers: Content-Type, Cache-Control, Expires, x-cos-meta-*...
+	etag     string
+	modified time.Time
+}
+
+type upload struct {
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+// storedHeaders are the request headers stored with an object, along with the x-cos-meta-* ones.
+var storedHeaders = []string{"Content-Type", "Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Expires"}
+
+const metaPrefix = "X-Cos-Meta-"
+
+// NewBucket starts serving an empty bucket.
+func NewBucket() *Bucket {
+	b := &Bucket{
+		objects: make(map[string]*object),
+		uploads: make(map[string]*upload),
+	}
+	b.Server = httptest.NewServer(http.HandlerFunc(b.serveHTTP))
+	return b
+}
+
+// URL returns the URL of the bucket, to be used as the bucket URL of a COS client.
+func (b *Bucket) URL() *url.URL {
+	bucketURL, _ := url.Parse(b.Server.URL)
+	return bucketURL
+}
+
+// Object returns the content of an object, and whether it exists.
+func (b *Bucket) Object(key string) ([]byte, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(obj.data), true
+}
+
+// Keys returns the keys of all objects, sorted.
+func (b *Bucket) Keys() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.sortedKeys()
+}
+
+// Uploads returns the number of multipart uploads neither completed nor aborted.
+func (b *Bucket) Uploads() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.uploads)
+}
+
+func (b *Bucket) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	if b.Fail != nil {
+		if failure := b.Fail(r, body); failure != nil {
+			writeError(w, failure.Status, failure.Code)
+			return
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodGet:
+		b.list(w, query)
+	case r.Method == http.MethodPut && r.Header.Get("x-cos-copy-source") != "":
+		b.copy(w, r, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		b.uploadPart(w, r, query, body)
+	case r.Method == http.MethodPut:
+		b.put(w, r, key, body)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		b.nextID++
+		uploadID := "upload-" + strconv.Itoa(b.nextID)
+		b.uploads[uploadID] = &upload{key: key, header: storedHeader(r.Header), parts: make(map[int][]byte)}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		b.completeUpload(w, query, key, body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		if _, ok := b.uploads[query.Get("uploadId")]; !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		delete(b.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		obj, ok := b.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		writeObjectHeader(w, obj)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (b *Bucket) list(w http.ResponseWriter, query url.Values) {
+	maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+	if err != nil || maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	type content struct {
+		Key          string
+		ETag         string
+		Size         int
+		LastModified string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Prefix      string
+		Marker      string
+		IsTruncated bool
+		Contents    []content
+	}{Prefix: query.Get("prefix"), Marker: query.Get("marker")}
+	for _, key := range b.sortedKeys() {
+		if !strings.HasPrefix(key, result.Prefix) || key <= result.Marker {
+			continue
+		}
+		if len(result.Contents) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		obj := b.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			ETag:         obj.etag,
+			Size:         len(obj.data),
+			LastModified: obj.modified.Format(time.RFC3339),
+		})
+	}
+	writeXML(w, result)
+}
+
+func (b *Bucket) put(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		sum := md5.Sum(body)
+		if contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+			writeError(w, http.StatusBadRequest, "BadDigest")
+			return
+		}
+	}
+	obj := b.store(key, body, storedHeader(r.Header))
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("x-cos-hash-crc64ecma", crc64ECMA(obj.data))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *Bucket) copy(w http.ResponseWriter, r *http.Request, key string) {
+	source := r.Header.Get("x-cos-copy-source")
+	_, sourcePath, _ := strings.Cut(source, "/")
+	sourceKey, err := url.PathUnescape(sourcePath)
+	if err != nil || strings.Contains(sourcePath, "?") {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	src, ok := b.objects[sourceKey]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	if ifMatch := r.Header.Get("x-cos-copy-source-If-Match"); ifMatch != "" && ifMatch != src.etag {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	header := src.header
+	if r.Header.Get("x-cos-metadata-directive") == "Replaced" {
+		header = storedHeader(r.Header)
+	}
+	obj := b.store(key, src.data, header)
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+		CRC64        string
+	}{ETag: obj.etag, LastModified: obj.modified.Format(time.RFC3339), CRC64: crc64ECMA(obj.data)})
+}
+
+func (b *Bucket) uploadPart(w http.ResponseWriter, r *http.Request, query url.Values, body []byte) {
+	up, ok := b.uploads[query.Get("uploadId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	up.parts[partNumber] = body
+	w.Header().Set("ETag", etag(body))
+	w.Header().Set("x-cos-hash-crc64ecma", crc64ECMA(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *Bucket) completeUpload(w http.ResponseWriter, query url.Values, key string, body []byte) {
+	up, ok := b.uploads[query.Get("uploadId")]
+	if !ok || up.key != key {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var request struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &request); err != nil || len(request.Parts) == 0 {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var data []byte
+	for i, part := range request.Parts {
+		partData, ok := up.parts[part.PartNumber]
+		if !ok || etag(partData) != part.ETag || (i > 0 && part.PartNumber <= request.Parts[i-1].PartNumber) {
+			writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		data = append(data, partData...)
+	}
+	delete(b.uploads, query.Get("uploadId"))
+	obj := b.store(key, data, up.header)
+	obj.etag = fmt.Sprintf(`"%s-%d"`, strings.Trim(obj.etag, `"`), len(request.Parts))
+	w.Header().Set("x-cos-hash-crc64ecma", crc64ECMA(obj.data))
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Key     string
+		ETag    string
+	}{Key: key, ETag: obj.etag})
+}
+
+func (b *Bucket) store(key string, data []byte, header http.Header) *object {
+	obj := &object{
+		data:     data,
+		header:   header,
+		etag:     etag(data),
+		modified: time.Now().UTC(),
+	}
+	b.objects[key] = obj
+	return obj
+}
+
+func (b *Bucket) sortedKeys() []string {
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// storedHeader returns the headers of a request stored with the object.
+func storedHeader(requestHeader http.Header) http.Header {
+	header := make(http.Header)
+	for _, key := range storedHeaders {
+		if value := requestHeader.Get(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+	for key, values := range requestHeader {
+		if strings.HasPrefix(http.CanonicalHeaderKey(key), metaPrefix) {
+			header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	return header
+}
+
+func writeObjectHeader(w http.ResponseWriter, obj *object) {
+	for key, values := range obj.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+	w.Header().Set("x-cos-hash-crc64ecma", crc64ECMA(obj.data))
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message><RequestId>costest</RequestId></Error>", code, http.StatusText(status))
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func crc64ECMA(data []byte) string {
+	return strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+)
+
+// ErrChecksumMismatch is returned when an uploaded object does not match its expected checksums.
+var ErrChecksumMismatch = apierror.New(apierror.CodeChecksumMismatch, "checksum mismatch")
+
+// IsNotFound reports whether an error of a backend call means that the object does not exist.
+func IsNotFound(err error) bool {
+	var apiErr *apierror.Error
+	return errors.As(err, &apiErr) && apiErr.Code == apierror.CodeNotFound
+}
+
+// backendError maps an error of a backend call to an apierror.Error, keeping the original error as its cause.
+func backendError(backend string, err error) error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	var cosErr *cos.ErrorResponse
+	if errors.As(err, &cosErr) {
+		return cosError(cosErr)
+	}
+	if mapped := apierror.From(err); mapped.Code != apierror.CodeInternal {
+		return mapped
+	}
+	if backend == backendCDN {
+		return apierror.Wrap(apierror.CodeBackendError, "cdn error", err)
+	}
+	return apierror.Wrap(apierror.CodeBackendError, "storage error", err)
+}
+
+// cosError maps an error response of COS, by its status and error code.
+func cosError(err *cos.ErrorResponse) *apierror.Error {
+	switch err.Code {
+	case "InvalidDigest", "BadDigest":
+		return apierror.Wrap(apierror.CodeChecksumMismatch, "checksum mismatch", err)
+	case "EntityTooLarge":
+		return apierror.Wrap(apierror.CodeTooLarge, "object too large", err)
+	case "SlowDown":
+		return apierror.Wrap(apierror.CodeRateLimited, "storage rate limit exceeded", err)
+	}
+	switch status := err.Response.StatusCode; {
+	case status == http.StatusNotFound:
+		return apierror.Wrap(apierror.CodeNotFound, "object not found", err)
+	case status == http.StatusConflict:
+		return apierror.Wrap(apierror.CodeConflict, "object conflict", err)
+	case status == http.StatusPreconditionFailed:
+		return apierror.Wrap(apierror.CodePreconditionFailed, "precondition failed", err)
+	case status == http.StatusTooManyRequests:
+		return apierror.Wrap(apierror.CodeRateLimited, "storage rate limit exceeded", err)
+	case status == http.StatusBadRequest:
+		return apierror.Wrap(apierror.CodeInvalidRequest, "invalid request to storage", err)
+	case status == http.StatusForbidden:
+		// Tenants never talk to COS, so this is a misconfiguration of the bucket or its credentials
+		return apierror.Wrap(apierror.CodeBackendDenied, "storage access denied", err)
+	default:
+		return apierror.Wrap(apierror.CodeBackendError, "storage error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+)
+
+// cosErrorResponse is an error response of COS to a GET request.
+func cosErrorResponse(status int, code string) *cos.ErrorResponse {
+	req, _ := http.NewRequest(http.MethodGet, "https://bucket.cos.ap-shanghai.myqcloud.com/a.png", nil)
+	return &cos.ErrorResponse{
+		Response: &http.Response{StatusCode: status, Header: http.Header{}, Request: req},
+		Code:     code,
+	}
+}
+
+func TestCOSError(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		code   string
+		want   apierror.Code
+	}{
+		{http.StatusBadRequest, "InvalidDigest", apierror.CodeChecksumMismatch},
+		{http.StatusBadRequest, "BadDigest", apierror.CodeChecksumMismatch},
+		{http.StatusBadRequest, "EntityTooLarge", apierror.CodeTooLarge},
+		{http.StatusServiceUnavailable, "SlowDown", apierror.CodeRateLimited},
+		{http.StatusBadRequest, "InvalidArgument", apierror.CodeInvalidRequest},
+		{http.StatusForbidden, "AccessDenied", apierror.CodeBackendDenied},
+		{http.StatusForbidden, "SignatureDoesNotMatch", apierror.CodeBackendDenied},
+		{http.StatusNotFound, "NoSuchKey", apierror.CodeNotFound},
+		{http.StatusConflict, "ObjectLocked", apierror.CodeConflict},
+		{http.StatusPreconditionFailed, "PreconditionFailed", apierror.CodePreconditionFailed},
+		{http.StatusTooManyRequests, "", apierror.CodeRateLimited},
+		{http.StatusInternalServerError, "InternalError", apierror.CodeBackendError},
+		{http.StatusServiceUnavailable, "ServiceUnavailable", apierror.CodeBackendError},
+	} {
+		cosErr := cosErrorResponse(tc.status, tc.code)
+		got := cosError(cosErr)
+		if got.Code != tc.want {
+			t.Errorf("%d %s: %s, want %s", tc.status, tc.code, got.Code, tc.want)
+		}
+		if !errors.Is(got, cosErr) {
+			t.Errorf("%d %s: the COS error is not kept as cause", tc.status, tc.code)
+		}
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	revoked := apierror.New(apierror.CodeRevoked, "revoked")
+	for name, tc := range map[string]struct {
+		backend string
+		err     error
+		want    apierror.Code
+	}{
+		"API error kept":      {backendCOS, revoked, apierror.CodeRevoked},
+		"COS error response":  {backendCOS, fmt.Errorf("head: %w", cosErrorResponse(http.StatusForbidden, "AccessDenied")), apierror.CodeBackendDenied},
+		"timeout":             {backendCOS, context.DeadlineExceeded, apierror.CodeTimeout},
+		"other storage error": {backendCOS, errors.New("boom"), apierror.CodeBackendError},
+		"other CDN error":     {backendCDN, errors.New("boom"), apierror.CodeBackendError},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var apiErr *apierror.Error
+			if err := backendError(tc.backend, tc.err); !errors.As(err, &apiErr) || apiErr.Code != tc.want {
+				t.Errorf("backendError(%v) = %v, want %s", tc.err, err, tc.want)
+			}
+		})
+	}
+}
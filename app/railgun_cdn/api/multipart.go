@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// MaxUploadParts is the maximum number of parts of a multipart upload.
+const MaxUploadParts = 10000
+
+type InitiateUploadResponse struct {
+	UploadID string `json:"uploadId"`
+}
+
+// UploadedPart is a part of a multipart upload, identified by its number and the ETag returned when it was uploaded.
+type UploadedPart struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+type UploadPartResponse struct {
+	UploadedPart
+	CRC64 string `json:"crc64"`
+}
+
+// InitiateUpload starts a multipart upload of an object to COS and returns its upload ID.
+// The headers and TTL of the object are set now, the object exists once the upload is completed.
+func InitiateUpload(ctx context.Context, objectKey string, headers ObjectHeaders, ttl int64) (_ InitiateUploadResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "InitiateUpload")
+	defer call.end(&err)
+	result, _, err := cosClient.Object.InitiateMultipartUpload(ctx, objectKey, &cos.InitiateMultipartUploadOptions{
+		ObjectPutHeaderOptions: putHeaderOptions(headers, ttl),
+		ACLHeaderOptions: &cos.ACLHeaderOptions{
+			XCosACL: "private",
+		},
+	})
+	if err != nil {
+		return InitiateUploadResponse{}, err
+	}
+	if result == nil || result.UploadID == "" {
+		return InitiateUploadResponse{}, errors.New("empty response from storage")
+	}
+	return InitiateUploadResponse{UploadID: result.UploadID}, nil
+}
+
+// UploadPart uploads a part of size bytes of a multipart upload to COS, replacing the part with the same number if any.
+// The checksums of the part are verified like those of PutObject, ErrChecksumMismatch is returned on mismatch,
+// in which case the part must be uploaded again.
+func UploadPart(ctx context.Context, objectKey, uploadID string, partNumber int, dataStream io.Reader, size int64, checksums Checksums) (_ UploadPartResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "UploadPart")
+	defer call.end(&err)
+	sums := newChecksummer()
+	// COS does not accept chunked parts, so the size must be known
+	resp, err := cosClient.Object.UploadPart(ctx, objectKey, uploadID, partNumber, sums.tee(dataStream), &cos.ObjectUploadPartOptions{
+		ContentLength: size,
+	})
+	if err != nil {
+		return UploadPartResponse{}, err
+	}
+	if resp == nil {
+		return UploadPartResponse{}, errors.New("empty response from storage")
+	}
+	res := UploadPartResponse{
+		UploadedPart: UploadedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")},
+		CRC64:        resp.Header.Get("x-cos-hash-crc64ecma"),
+	}
+	if !sums.matches(checksums, res.CRC64) {
+		return UploadPartResponse{}, ErrChecksumMismatch
+	}
+	return res, nil
+}
+
+// CompleteUpload assembles the parts of a multipart upload into the object, in ascending order of part numbers.
+// The CRC-64 of the whole object is verified against the expected one, the object is deleted and
+// ErrChecksumMismatch is returned on mismatch. Other checksums cannot be verified without reading the object back.
+func CompleteUpload(ctx context.Context, objectKey, uploadID string, parts []UploadedPart, checksums Checksums) (_ PutObjectResponse, err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "CompleteUpload")
+	defer call.end(&err)
+	opt := &cos.CompleteMultipartUploadOptions{
+		Parts: make([]cos.Object, len(parts)),
+	}
+	for i, part := range parts {
+		opt.Parts[i] = cos.Object{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+	result, resp, err := cosClient.Object.CompleteMultipartUpload(ctx, objectKey, uploadID, opt)
+	if err != nil {
+		return PutObjectResponse{}, err
+	}
+	if result == nil || resp == nil {
+		return PutObjectResponse{}, errors.New("empty response from storage")
+	}
+	res := PutObjectResponse{
+		ETag:  result.ETag,
+		CRC64: resp.Header.Get("x-cos-hash-crc64ecma"),
+	}
+	if checksums.CRC64 != "" && res.CRC64 != "" && checksums.CRC64 != res.CRC64 {
+		if err := DeleteObject(ctx, objectKey); err != nil {
+			return PutObjectResponse{}, errors.Join(ErrChecksumMismatch, err)
+		}
+		return PutObjectResponse{}, ErrChecksumMismatch
+	}
+	return res, nil
+}
+
+// AbortUpload cancels a multipart upload and deletes its uploaded parts from COS.
+func AbortUpload(ctx context.Context, objectKey, uploadID string) (err error) {
+	ctx, call := startBackendCall(ctx, backendCOS, "AbortUpload")
+	defer call.end(&err)
+	_, err = cosClient.Object.AbortMultipartUpload(ctx, objectKey, uploadID)
+	return err
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tundrawork/stargate/app/common/tracing"
+)
+
+const (
+	backendCOS = "cos"
+	backendCDN = "cdn"
+)
+
+var (
+	backendRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stargate_backend_request_duration_seconds",
+		Help:    "Time taken by calls to the storage and CDN backends, by backend and operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+	backendErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stargate_backend_errors_total",
+		Help: "Number of failed calls to the storage and CDN backends, by backend and operation.",
+	}, []string{"backend", "operation"})
+)
+
+// backendCall is an ongoing call to a backend, observed with a span and metrics.
+type backendCall struct {
+	backend   string
+	operation string
+	start     time.Time
+	span      trace.Span
+}
+
+// startBackendCall starts observing a backend call, the returned context carries its span.
+// The call must be ended with a deferred end, given a pointer to the named error result of the call.
+func startBackendCall(ctx context.Context, backend, operation string) (context.Context, *backendCall) {
+	ctx, span := tracing.Tracer().Start(ctx, backend+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("stargate.backend", backend),
+			attribute.String("stargate.operation", operation),
+		),
+	)
+	return ctx, &backendCall{
+		backend:   backend,
+		operation: operation,
+		start:     time.Now(),
+		span:      span,
+	}
+}
+
+// end records the latency and outcome of the call, and maps its error to an apierror.Error.
+func (b *backendCall) end(err *error) {
+	backendRequestDuration.WithLabelValues(b.backend, b.operation).Observe(time.Since(b.start).Seconds())
+	if *err != nil {
+		backendErrors.WithLabelValues(b.backend, b.operation).Inc()
+		b.span.RecordError(*err)
+		b.span.SetStatus(codes.Error, (*err).Error())
+		*err = backendError(b.backend, *err)
+	}
+	b.span.End()
+}
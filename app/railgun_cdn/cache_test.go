@@ -0,0 +1,98 @@
+package railgun_cdn
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/config"
+)
+
+// newCacheTestServer serves the cache handlers for tenant app-a, backed by a mock CDN cache.
+func newCacheTestServer(t *testing.T) (*server.Hertz, *api.MockCDNCache) {
+	t.Helper()
+	config.Conf.Services.RailgunCDN.CDN.Endpoint = "https://cdn.example.com"
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {AppKey: "key-a", RootPath: "app-a"},
+	}
+	mock := &api.MockCDNCache{}
+	api.SetCDNCache(mock)
+	h := server.New()
+	h.POST("/cache/purge", PurgeCache)
+	h.POST("/cache/prefetch", PrefetchCache)
+	return h, mock
+}
+
+func performCacheRequest(h *server.Hertz, path, body string) *ut.ResponseRecorder {
+	return ut.PerformRequest(h.Engine, http.MethodPost, path,
+		&ut.Body{Body: bytes.NewBufferString(body), Len: len(body)},
+		ut.Header{Key: "X-App-Id", Value: "app-a"},
+		ut.Header{Key: "X-App-Key", Value: "key-a"},
+		ut.Header{Key: "Content-Type", Value: "application/json"},
+	)
+}
+
+func TestPurgeCache(t *testing.T) {
+	h, mock := newCacheTestServer(t)
+
+	w := performCacheRequest(h, "/cache/purge", `{"objectPaths":["/a.png","/b.png"],"directories":["/img/"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if len(mock.Tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2: %+v", len(mock.Tasks), mock.Tasks)
+	}
+	urls, paths := mock.Tasks[0], mock.Tasks[1]
+	if urls.Action != "PurgeUrlsCache" || !slices.Equal(urls.Targets, []string{"https://cdn.example.com/app-a/a.png", "https://cdn.example.com/app-a/b.png"}) {
+		t.Errorf("unexpected URL purge task: %+v", urls)
+	}
+	if paths.Action != "PurgePathCache" || !slices.Equal(paths.Targets, []string{"https://cdn.example.com/app-a/img/"}) {
+		t.Errorf("unexpected path purge task: %+v", paths)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"taskIds":["mock-1","mock-2"]`)) {
+		t.Errorf("response does not list the task IDs: %s", w.Body.String())
+	}
+}
+
+func TestPrefetchCache(t *testing.T) {
+	h, mock := newCacheTestServer(t)
+
+	w := performCacheRequest(h, "/cache/prefetch", `{"objectPaths":["/a.png"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if len(mock.Tasks) != 1 || mock.Tasks[0].Action != "PushUrlsCache" ||
+		!slices.Equal(mock.Tasks[0].Targets, []string{"https://cdn.example.com/app-a/a.png"}) {
+		t.Errorf("unexpected prefetch tasks: %+v", mock.Tasks)
+	}
+}
+
+func TestCacheRequestRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"prefetch directories", "/cache/prefetch", `{"directories":["/img/"]}`},
+		{"no targets", "/cache/purge", `{}`},
+		{"invalid object path", "/cache/purge", `{"objectPaths":["a.png"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newCacheTestServer(t)
+
+			w := performCacheRequest(h, tt.path, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+			if len(mock.Tasks) != 0 {
+				t.Errorf("got tasks for a rejected request: %+v", mock.Tasks)
+			}
+		})
+	}
+}
@@ -1,18 +1,51 @@
 package railgun_cdn
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/url"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/tundrawork/stargate/app/common/accesslog"
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/logging"
+	"github.com/tundrawork/stargate/app/common/metrics"
+	"github.com/tundrawork/stargate/app/common/requestid"
+	"github.com/tundrawork/stargate/app/common/tracing"
 	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/app/railgun_cdn/revocation"
 	"github.com/tundrawork/stargate/config"
 )
 
+// maxBatchSize is the maximum number of objects in a single batch request.
+const maxBatchSize = 1000
+
+const (
+	// storageHealthTTL is how long the result of the storage health check is cached.
+	storageHealthTTL = 10 * time.Second
+	// storageHealthTimeout bounds the storage health check.
+	storageHealthTimeout = 3 * time.Second
+)
+
 type TenantBusinessData struct {
-	AppID    string
-	RootPath string
-	SiteID   string
+	AppID       string
+	RootPath    string
+	SiteID      string
+	AutoPurge   bool
+	TrackEvents bool
+	Sinks       []string
+	Dimensions  config.RailgunCDNTenantDimensions
+	Privacy     config.AnalyticsPrivacy
 }
 
 // isValidObjectPath checks if the object path is valid.
@@ -21,18 +54,62 @@ func isValidObjectPath(objectPath string) bool {
 	return len(objectPath) > 0 && objectPath[0] == '/' && objectPath[len(objectPath)-1] != '/'
 }
 
+// isValidDirectoryPath checks if the directory path is valid.
+func isValidDirectoryPath(directoryPath string) bool {
+	// The directory path must start and end with a slash.
+	return len(directoryPath) > 0 && directoryPath[0] == '/' && directoryPath[len(directoryPath)-1] == '/'
+}
+
+// checkTenants exits if the root path of a tenant cannot hold its objects, see api.CheckRootPath.
+func checkTenants() {
+	for appID, tenant := range config.Conf.Services.RailgunCDN.Tenants {
+		if err := api.CheckRootPath(tenant.RootPath); err != nil {
+			hlog.Fatalf("[RailgunCDN] Invalid root path of tenant %s: %v", appID, err)
+		}
+	}
+}
+
 // authTenant authenticates the tenant from the common tenant request and returns the tenant's root path.
-func authTenant(req *CommonTenantRequest) (*TenantBusinessData, error) {
-	if tenant, ok := config.Conf.Services.RailgunCDN.Tenants[req.AppID]; ok {
-		if tenant.AppKey == req.AppKey {
-			return &TenantBusinessData{
-				AppID:    req.AppID,
-				RootPath: tenant.RootPath,
-				SiteID:   tenant.SiteID,
-			}, nil
+func authTenant(ctx context.Context, req *CommonTenantRequest) (*TenantBusinessData, error) {
+	_, span := tracing.Tracer().Start(ctx, "railgun_cdn.authTenant")
+	defer span.End()
+	logging.AddFields(ctx, slog.String("app_id", req.AppID), slog.String("object_path", req.ObjectPath))
+	tenant, ok := config.Conf.Services.RailgunCDN.Tenants[req.AppID]
+	if !ok {
+		authFailures.WithLabelValues(authFailureUnknownApp).Inc()
+		span.SetStatus(codes.Error, authFailureUnknownApp)
+		return nil, apierror.New(apierror.CodeUnauthorized, "tenant authorization failed")
+	}
+	if len(req.AppKey) == 0 {
+		// Authenticated by the client certificate alone
+		if !slices.ContainsFunc(req.clientCertNames, func(name string) bool {
+			return slices.Contains(tenant.ClientCertNames, name)
+		}) {
+			authFailures.WithLabelValues(authFailureInvalidCert).Inc()
+			span.SetStatus(codes.Error, authFailureInvalidCert)
+			return nil, apierror.New(apierror.CodeUnauthorized, "tenant authorization failed")
 		}
+	} else if tenant.AppKey != req.AppKey {
+		authFailures.WithLabelValues(authFailureInvalidKey).Inc()
+		span.SetStatus(codes.Error, authFailureInvalidKey)
+		return nil, apierror.New(apierror.CodeUnauthorized, "tenant authorization failed")
+	}
+	span.SetAttributes(attribute.String("stargate.app_id", req.AppID))
+	return newTenantBusinessData(req.AppID, tenant), nil
+}
+
+// newTenantBusinessData builds the business data of a tenant from its configuration.
+func newTenantBusinessData(appID string, tenant config.RailgunCDNTenant) *TenantBusinessData {
+	return &TenantBusinessData{
+		AppID:       appID,
+		RootPath:    tenant.RootPath,
+		SiteID:      tenant.SiteID,
+		AutoPurge:   tenant.AutoPurge,
+		TrackEvents: tenant.TrackEvents,
+		Sinks:       tenant.Sinks,
+		Dimensions:  tenant.Dimensions,
+		Privacy:     tenant.Privacy,
 	}
-	return nil, errors.New("tenant authorization failed")
 }
 
 // getObjectPrivateURL gets the private CDN URL of an object.
@@ -42,13 +119,116 @@ func getObjectPrivateURL(tenant *TenantBusinessData, tenantRequest *CommonTenant
 	if err != nil {
 		return "", -1, err
 	}
+	issuedAt := time.Now().Unix()
 	privateURL = fmt.Sprintf(
-		"%s?a=%s&o=%s&s=%s&t=%d",
+		"%s?a=%s&o=%s&s=%s&t=%d&i=%d&v=%s",
 		config.Conf.Services.RailgunCDN.Private.Endpoint,
 		url.QueryEscape(tenant.AppID),
 		url.QueryEscape(tenantRequest.ObjectPath),
 		sign,
 		timestamp,
+		issuedAt,
+		api.SignIssuance(sign, issuedAt),
 	)
 	return privateURL, expires, nil
 }
+
+// revokeLinks records the revocation targets of a revoke request in the revocation store.
+func revokeLinks(tenant *TenantBusinessData, revokeRequest *RevokeRequest) error {
+	if revokeRequest.sign != "" {
+		if err := revocation.RevokeSign(tenant.AppID, revokeRequest.sign, revokeRequest.expires); err != nil {
+			return err
+		}
+	}
+	if revokeRequest.ObjectPath != "" {
+		if err := revocation.RevokePath(tenant.AppID, revokeRequest.ObjectPath); err != nil {
+			return err
+		}
+	}
+	if revokeRequest.Before > 0 {
+		if err := revocation.RevokeBefore(tenant.AppID, revokeRequest.Before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// autoPurgeObject purges the CDN cache of an overwritten or deleted object in background, if the tenant enabled it.
+func autoPurgeObject(ctx context.Context, tenant *TenantBusinessData, objectPath string) {
+	if !tenant.AutoPurge {
+		return
+	}
+	go func() {
+		// Detach from the request context, which is canceled once the response is sent.
+		purgeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		taskID, err := api.PurgeURLs(purgeCtx, []string{api.GetObjectCDNURL(tenant.AppID, objectPath)})
+		if err != nil {
+			hlog.CtxErrorf(purgeCtx, "[RailgunCDN][Error] Method=%s AppID=%s ObjectPath=%s Error=%s", "AutoPurge", tenant.AppID, objectPath, err.Error())
+			return
+		}
+		hlog.CtxInfof(purgeCtx, "[RailgunCDN][AutoPurge] AppID=%s ObjectPath=%s TaskID=%s", tenant.AppID, objectPath, taskID)
+	}()
+}
+
+// reportEvent applies the privacy settings of the tenant to the analytics event of a request, then records it in the
+// access log and reports it to the sinks of the tenant. Nothing is recorded for a request the tenant must not track.
+// The request metrics are labeled with the tenant as well.
+func reportEvent(ctx context.Context, c *app.RequestContext, tenant *TenantBusinessData, event analytics.Event) {
+	metrics.SetTenant(c, tenant.AppID)
+	doNotTrack := analytics.DoNotTrack(string(c.GetHeader("DNT")), string(c.GetHeader("Sec-GPC")))
+	event, ok := analytics.ApplyPrivacy(event, tenant.Privacy, event.EventName, doNotTrack)
+	if !ok {
+		return
+	}
+	accesslog.Log(ctx, accesslog.Record{Event: event, DoNotTrack: doNotTrack})
+	analytics.Report(ctx, tenant.Sinks, event)
+}
+
+// newEvent builds the analytics event of a request from its outcome, it must be called after the response is written.
+// The object path is used as the event name. It is tracked by Matomo as an event only if the tenant opted in, and as a
+// pageview otherwise.
+func newEvent(c *app.RequestContext, tenant *TenantBusinessData, action string, objectPath string, start time.Time) analytics.Event {
+	event := analytics.Event{
+		AppID:          tenant.AppID,
+		SiteID:         tenant.SiteID,
+		ActionName:     "railgun_cdn:" + action,
+		URL:            config.Conf.Services.RailgunCDN.CDN.Endpoint + objectPath,
+		UserAgent:      string(c.UserAgent()),
+		ClientIP:       c.ClientIP(),
+		ClientTime:     start,
+		Status:         c.Response.StatusCode(),
+		ResponseSize:   int64(len(c.Response.Body())),
+		Duration:       time.Since(start),
+		RequestID:      requestid.Get(c),
+		Referer:        string(c.GetHeader("Referer")),
+		AcceptLanguage: string(c.GetHeader("Accept-Language")),
+		EventAction:    action,
+		EventName:      objectPath,
+	}
+	if tenant.TrackEvents {
+		event.EventCategory = "railgun_cdn"
+	}
+	if tenant.Dimensions.Status > 0 || tenant.Dimensions.RequestID > 0 {
+		event.CustomDimensions = make(map[int]string)
+		if tenant.Dimensions.Status > 0 {
+			event.CustomDimensions[tenant.Dimensions.Status] = strconv.Itoa(event.Status)
+		}
+		if tenant.Dimensions.RequestID > 0 {
+			event.CustomDimensions[tenant.Dimensions.RequestID] = event.RequestID
+		}
+	}
+	return event
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,79 @@
+package railgun_cdn
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/app/railgun_cdn/revocation"
+	"github.com/tundrawork/stargate/config"
+)
+
+func TestClientGatewayRedirectIsNotCached(t *testing.T) {
+	config.Conf.Services.RailgunCDN.CDN.Endpoint = "https://cdn.example.com"
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {AppKey: "key-a", RootPath: "app-a"},
+	}
+	h := server.New()
+	h.GET("/gateway", ClientGateway)
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/gateway?a=app-a&o=/a.png&s=sign&t=1700000000", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want a temporary redirect: %s", w.Code, w.Body.String())
+	}
+	if location := w.Header().Get("Location"); location != "https://cdn.example.com/app-a/a.png?sign=sign&t=1700000000" {
+		t.Errorf("Location = %q", location)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store so that revocations apply to the next request", cacheControl)
+	}
+}
+
+func TestClientGatewayRejectsRevokedLinks(t *testing.T) {
+	config.Conf.Services.RailgunCDN.CDN.Endpoint = "https://cdn.example.com"
+	config.Conf.Services.RailgunCDN.CDN.PKey = "pkey"
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-r": {AppKey: "key-r", RootPath: "app-r"},
+	}
+	revocation.InitStore("", 3600)
+	now := time.Now().Unix()
+	if err := revocation.RevokeSign("app-r", "revoked-sign", now+600); err != nil {
+		t.Fatal(err)
+	}
+	if err := revocation.RevokePath("app-r", "/revoked.png"); err != nil {
+		t.Fatal(err)
+	}
+	h := server.New()
+	h.GET("/gateway", ClientGateway)
+
+	issued := func(sign string, issuedAt int64) string {
+		return fmt.Sprintf("&i=%d&v=%s", issuedAt, api.SignIssuance(sign, issuedAt))
+	}
+	for _, tc := range []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"revoked sign", "o=/a.png&s=revoked-sign", http.StatusGone},
+		{"revoked path", "o=/revoked.png&s=sign" + issued("sign", now-10), http.StatusGone},
+		{"revoked path without issue time", "o=/revoked.png&s=sign", http.StatusGone},
+		{"path revoked before issue", "o=/revoked.png&s=sign" + issued("sign", now+10), http.StatusFound},
+		{"forged issue time", "o=/revoked.png&s=sign" + fmt.Sprintf("&i=%d&v=forged", now+10), http.StatusBadRequest},
+		{"live link", "o=/a.png&s=sign", http.StatusFound},
+	} {
+		w := ut.PerformRequest(h.Engine, http.MethodGet, "/gateway?a=app-r&t=1700000000&"+tc.query, nil)
+		if w.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, w.Code, tc.status, w.Body.String())
+		}
+		if tc.status == http.StatusGone && !strings.Contains(w.Body.String(), string(apierror.CodeRevoked)) {
+			t.Errorf("%s: body %s, want the %s code", tc.name, w.Body.String(), apierror.CodeRevoked)
+		}
+	}
+}
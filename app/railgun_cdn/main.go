@@ -2,247 +2,473 @@ package railgun_cdn
 
 import (
 	"context"
-	"errors"
+	"log/slog"
 	"strconv"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
-	"github.com/tencentyun/cos-go-sdk-v5"
 
 	"github.com/tundrawork/stargate/app/common"
-	"github.com/tundrawork/stargate/app/common/matomo"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/health"
+	"github.com/tundrawork/stargate/app/common/logging"
 	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/app/railgun_cdn/revocation"
 	"github.com/tundrawork/stargate/config"
 )
 
 // Init initializes the Railgun CDN service.
 func Init() {
+	checkTenants()
 	api.InitCosClient(
 		config.Conf.Services.RailgunCDN.COS.Bucket,
 		config.Conf.Services.RailgunCDN.COS.Region,
 		config.Conf.Services.RailgunCDN.COS.SecretID,
 		config.Conf.Services.RailgunCDN.COS.SecretKey,
 	)
+	api.InitCDNCache(
+		config.Conf.Services.RailgunCDN.CDN.API.Endpoint,
+		config.Conf.Services.RailgunCDN.CDN.API.SecretID,
+		config.Conf.Services.RailgunCDN.CDN.API.SecretKey,
+		config.Conf.Services.RailgunCDN.CDN.API.Mock,
+	)
+	health.Register("storage", true, storageHealthTTL, storageHealthTimeout, api.CheckBucket)
+	revocation.InitStore(config.Conf.Services.RailgunCDN.Revocation.StorePath, api.MaxTTL())
+	initWebsites()
 }
 
 // GetBucket lists all objects in a bucket.
 func GetBucket(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	tenantRequest := &CommonTenantRequest{}
 	if err := tenantRequest.FromRequestContext(c); err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, err.Error()))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
 		return
 	}
-	tenant, err := authTenant(tenantRequest)
+	tenant, err := authTenant(ctx, tenantRequest)
 	if err != nil {
-		c.JSON(consts.StatusUnauthorized, common.APIResponseError(consts.StatusUnauthorized, err.Error()))
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:GetBucket", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "GetBucket")
+	prefix := string(c.GetHeader("X-Prefix"))
+	if prefix != "" && prefix[0] != '/' {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid prefix"))
 		return
 	}
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s AppID=%s", "GetBucket", tenantRequest.AppID)
-	prefix := tenant.RootPath
-	resp, err := api.GetBucket(ctx, prefix)
+	withMetadata := string(c.GetHeader("X-With-Metadata")) == "true"
+	resp, err := api.GetBucket(ctx, tenant.RootPath, prefix, withMetadata)
 	if err != nil {
-		var cosErr *cos.ErrorResponse
-		if errors.As(err, &cosErr) {
-			hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s AppID=%s StatusCode=%d", "GetBucket", tenantRequest.AppID, cosErr.Response.StatusCode)
-			c.JSON(cosErr.Response.StatusCode, common.APIResponseError(cosErr.Response.StatusCode, cosErr.Response.Status))
-			return
-		}
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "GetBucket", err.Error())
+		common.RenderError(c, err)
+		return
 	}
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     tenant.SiteID,
-		ActionName: "railgun_cdn:server:GetBucket",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + tenantRequest.ObjectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
 	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
 }
 
 // HeadObject returns the metadata of an object.
 func HeadObject(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	tenantRequest := &CommonTenantRequest{}
 	if err := tenantRequest.FromRequestContext(c); err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, err.Error()))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
 		return
 	}
-	tenant, err := authTenant(tenantRequest)
+	tenant, err := authTenant(ctx, tenantRequest)
 	if err != nil {
-		c.JSON(consts.StatusUnauthorized, common.APIResponseError(consts.StatusUnauthorized, err.Error()))
+		common.RenderError(c, err)
 		return
 	}
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s AppID=%s ObjectPath=%s", "HeadObject", tenantRequest.AppID, tenantRequest.ObjectPath)
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:HeadObject", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "HeadObject")
 	if tenantRequest.ObjectPath == "" {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "missing object path"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
 		return
 	}
 	objectKey := tenant.RootPath + tenantRequest.ObjectPath
 	resp, err := api.HeadObject(ctx, objectKey)
 	if err != nil {
-		var cosErr *cos.ErrorResponse
-		if errors.As(err, &cosErr) {
-			hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s AppID=%s StatusCode=%d", "HeadObject", tenantRequest.AppID, cosErr.Response.StatusCode)
-			c.JSON(cosErr.Response.StatusCode, common.APIResponseError(cosErr.Response.StatusCode, cosErr.Response.Status))
-			return
-		}
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "HeadObject", err.Error())
+		common.RenderError(c, err)
+		return
 	}
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     tenant.SiteID,
-		ActionName: "railgun_cdn:server:HeadObject",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + tenantRequest.ObjectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
 	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
 }
 
 // PutObject uploads an object.
 func PutObject(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	tenantRequest := &CommonTenantRequest{}
 	if err := tenantRequest.FromRequestContext(c); err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, err.Error()))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
 		return
 	}
-	tenant, err := authTenant(tenantRequest)
+	tenant, err := authTenant(ctx, tenantRequest)
 	if err != nil {
-		c.JSON(consts.StatusUnauthorized, common.APIResponseError(consts.StatusUnauthorized, err.Error()))
+		common.RenderError(c, err)
 		return
 	}
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s AppID=%s ObjectPath=%s", "PutObject", tenantRequest.AppID, tenantRequest.ObjectPath)
+	body := &countingReader{r: c.RequestBodyStream()}
+	defer func() {
+		event := newEvent(c, tenant, "server:PutObject", tenantRequest.ObjectPath, start)
+		event.BytesUploaded = body.n
+		reportEvent(ctx, c, tenant, event)
+		uploadBytes.WithLabelValues(tenant.AppID).Add(float64(body.n))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "PutObject")
 	if tenantRequest.ObjectPath == "" {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "missing object path"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
 		return
 	}
 	objectKey := tenant.RootPath + tenantRequest.ObjectPath
-	contentType := string(c.GetHeader("Content-Type"))
-	resp, err := api.PutObject(ctx, objectKey, c.RequestBodyStream(), contentType, tenantRequest.TTL)
+	headers := objectHeadersFromRequestContext(c)
+	checksums, err := checksumsFromRequestContext(c)
 	if err != nil {
-		var cosErr *cos.ErrorResponse
-		if errors.As(err, &cosErr) {
-			hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s AppID=%s StatusCode=%d", "PutObject", tenantRequest.AppID, cosErr.Response.StatusCode)
-			c.JSON(cosErr.Response.StatusCode, common.APIResponseError(cosErr.Response.StatusCode, cosErr.Response.Status))
-			return
-		}
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
 	}
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     tenant.SiteID,
-		ActionName: "railgun_cdn:server:PutObject",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + tenantRequest.ObjectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
+	resp, err := api.PutObject(ctx, objectKey, body, headers, checksums, tenantRequest.TTL)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "PutObject", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	autoPurgeObject(ctx, tenant, tenantRequest.ObjectPath)
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
+}
+
+// UpdateObject updates the headers and user metadata of an object without re-uploading it.
+func UpdateObject(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:UpdateObject", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "UpdateObject")
+	if tenantRequest.ObjectPath == "" {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
+		return
+	}
+	objectKey := tenant.RootPath + tenantRequest.ObjectPath
+	headers := objectHeadersFromRequestContext(c)
+	resp, err := api.UpdateObjectMetadata(ctx, objectKey, headers)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "UpdateObject", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	autoPurgeObject(ctx, tenant, tenantRequest.ObjectPath)
 	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
 }
 
 // DeleteObject deletes an object.
 func DeleteObject(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	tenantRequest := &CommonTenantRequest{}
 	if err := tenantRequest.FromRequestContext(c); err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, err.Error()))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
 		return
 	}
-	tenant, err := authTenant(tenantRequest)
+	tenant, err := authTenant(ctx, tenantRequest)
 	if err != nil {
-		c.JSON(consts.StatusUnauthorized, common.APIResponseError(consts.StatusUnauthorized, err.Error()))
+		common.RenderError(c, err)
 		return
 	}
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s AppID=%s ObjectPath=%s", "DeleteObject", tenantRequest.AppID, tenantRequest.ObjectPath)
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:DeleteObject", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "DeleteObject")
 	if tenantRequest.ObjectPath == "" {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "missing object path"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
 		return
 	}
 	objectKey := tenant.RootPath + tenantRequest.ObjectPath
 	if err := api.DeleteObject(ctx, objectKey); err != nil {
-		var cosErr *cos.ErrorResponse
-		if errors.As(err, &cosErr) {
-			hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s AppID=%s StatusCode=%d", "DeleteObject", tenantRequest.AppID, cosErr.Response.StatusCode)
-			c.JSON(cosErr.Response.StatusCode, common.APIResponseError(cosErr.Response.StatusCode, cosErr.Response.Status))
-			return
-		}
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "DeleteObject", err.Error())
+		common.RenderError(c, err)
+		return
 	}
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     tenant.SiteID,
-		ActionName: "railgun_cdn:server:DeleteObject",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + tenantRequest.ObjectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
+	autoPurgeObject(ctx, tenant, tenantRequest.ObjectPath)
 	c.JSON(consts.StatusOK, common.APIResponseSuccess(nil))
 }
 
 // GetURL returns the signed URL to access an object.
 func GetURL(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	tenantRequest := &CommonTenantRequest{}
 	if err := tenantRequest.FromRequestContext(c); err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, err.Error()))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
 		return
 	}
-	tenant, err := authTenant(tenantRequest)
+	tenant, err := authTenant(ctx, tenantRequest)
 	if err != nil {
-		c.JSON(consts.StatusUnauthorized, common.APIResponseError(consts.StatusUnauthorized, err.Error()))
+		common.RenderError(c, err)
 		return
 	}
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s AppID=%s ObjectPath=%s", "GetURL", tenantRequest.AppID, tenantRequest.ObjectPath)
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:GetURL", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "GetURL")
 	if tenantRequest.ObjectPath == "" {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "missing object path"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
 		return
 	}
 	privateURL, expires, err := getObjectPrivateURL(tenant, tenantRequest)
 	if err != nil {
-		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s AppID=%s Error=%s", "GetURL", tenantRequest.AppID, err.Error())
-		c.JSON(consts.StatusInternalServerError, common.APIResponseError(consts.StatusInternalServerError, err.Error()))
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "GetURL", err.Error())
+		common.RenderError(c, err)
 		return
 	}
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     tenant.SiteID,
-		ActionName: "railgun_cdn:server:GetURL",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + tenantRequest.ObjectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
 	c.JSON(consts.StatusOK, common.APIResponseSuccess(GetURLResponse{
 		URL:     privateURL,
 		Expires: expires,
 	}))
 }
 
+// GetURLs returns the signed URLs to access multiple objects.
+func GetURLs(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	signed := 0
+	defer func() {
+		event := newEvent(c, tenant, "server:GetURLs", tenantRequest.ObjectPath, start)
+		event.EventValue = float64(signed)
+		reportEvent(ctx, c, tenant, event)
+	}()
+	urlsRequest := &GetURLsRequest{}
+	if err := c.BindJSON(urlsRequest); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid request body"))
+		return
+	}
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s Objects=%d", "GetURLs", len(urlsRequest.Objects))
+	if err := urlsRequest.Validate(); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	resp := make(GetURLsResponse, len(urlsRequest.Objects))
+	for _, object := range urlsRequest.Objects {
+		if !isValidObjectPath(object.ObjectPath) {
+			resp[object.ObjectPath] = GetURLsResponseObject{Error: "invalid object path"}
+			continue
+		}
+		ttl := object.TTL
+		if ttl == 0 {
+			ttl = tenantRequest.TTL
+		}
+		privateURL, expires, err := getObjectPrivateURL(tenant, &CommonTenantRequest{
+			AppID:      tenantRequest.AppID,
+			AppKey:     tenantRequest.AppKey,
+			ObjectPath: object.ObjectPath,
+			TTL:        ttl,
+		})
+		if err != nil {
+			resp[object.ObjectPath] = GetURLsResponseObject{Error: err.Error()}
+			continue
+		}
+		resp[object.ObjectPath] = GetURLsResponseObject{
+			URL:     privateURL,
+			Expires: expires,
+		}
+		signed++
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
+}
+
+// PurgeCache purges the CDN cache of objects and directories.
+func PurgeCache(ctx context.Context, c *app.RequestContext) {
+	handleCacheRequest(ctx, c, "PurgeCache", true, func(cacheRequest *CacheRequest, tenant *TenantBusinessData) ([]string, error) {
+		var taskIDs []string
+		if len(cacheRequest.ObjectPaths) > 0 {
+			urls := make([]string, len(cacheRequest.ObjectPaths))
+			for i, objectPath := range cacheRequest.ObjectPaths {
+				urls[i] = api.GetObjectCDNURL(tenant.AppID, objectPath)
+			}
+			taskID, err := api.PurgeURLs(ctx, urls)
+			if err != nil {
+				return nil, err
+			}
+			taskIDs = append(taskIDs, taskID)
+		}
+		if len(cacheRequest.Directories) > 0 {
+			paths := make([]string, len(cacheRequest.Directories))
+			for i, directory := range cacheRequest.Directories {
+				paths[i] = api.GetObjectCDNURL(tenant.AppID, directory)
+			}
+			taskID, err := api.PurgePaths(ctx, paths)
+			if err != nil {
+				return nil, err
+			}
+			taskIDs = append(taskIDs, taskID)
+		}
+		return taskIDs, nil
+	})
+}
+
+// PrefetchCache prefetches objects to the CDN edge nodes.
+func PrefetchCache(ctx context.Context, c *app.RequestContext) {
+	handleCacheRequest(ctx, c, "PrefetchCache", false, func(cacheRequest *CacheRequest, tenant *TenantBusinessData) ([]string, error) {
+		urls := make([]string, len(cacheRequest.ObjectPaths))
+		for i, objectPath := range cacheRequest.ObjectPaths {
+			urls[i] = api.GetObjectCDNURL(tenant.AppID, objectPath)
+		}
+		taskID, err := api.PushURLs(ctx, urls)
+		if err != nil {
+			return nil, err
+		}
+		return []string{taskID}, nil
+	})
+}
+
+// handleCacheRequest implements the common flow of the CDN cache handlers.
+func handleCacheRequest(ctx context.Context, c *app.RequestContext, method string, allowDirectories bool, do func(*CacheRequest, *TenantBusinessData) ([]string, error)) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:"+method, tenantRequest.ObjectPath, start))
+	}()
+	cacheRequest := &CacheRequest{}
+	if err := c.BindJSON(cacheRequest); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid request body"))
+		return
+	}
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s ObjectPaths=%d Directories=%d", method, len(cacheRequest.ObjectPaths), len(cacheRequest.Directories))
+	if err := cacheRequest.Validate(allowDirectories); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	taskIDs, err := do(cacheRequest, tenant)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", method, err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(CacheResponse{
+		TaskIDs: taskIDs,
+	}))
+}
+
+// Revoke revokes signed URLs before they expire, by URL, by object path or by issue time.
+func Revoke(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:Revoke", tenantRequest.ObjectPath, start))
+	}()
+	revokeRequest := &RevokeRequest{}
+	if err := c.BindJSON(revokeRequest); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid request body"))
+		return
+	}
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s URL=%s ObjectPath=%s Before=%d", "Revoke", revokeRequest.URL, revokeRequest.ObjectPath, revokeRequest.Before)
+	if err := revokeRequest.Validate(tenant.AppID); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	if err := revokeLinks(tenant, revokeRequest); err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "Revoke", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(nil))
+}
+
 // ClientGateway handles the client access request and redirects it to the actual object URL.
 func ClientGateway(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
 	appId := c.Query("a")
 	objectPath := c.Query("o")
 	sign := c.Query("s")
 	timestamp := c.Query("t")
+	var tenant *TenantBusinessData
+	outcome := gatewayOutcomeInvalid
+	defer func() {
+		var tenantAppID string // Only known tenants are labeled, so that clients cannot create series at will
+		if tenant != nil {
+			tenantAppID = tenant.AppID
+		}
+		gatewayRequests.WithLabelValues(tenantAppID, outcome).Inc()
+	}()
 	if appId == "" || objectPath == "" || sign == "" || timestamp == "" {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "missing required parameter"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing required parameter"))
 		return
 	}
-	var siteId string
-	if tenant, ok := config.Conf.Services.RailgunCDN.Tenants[appId]; ok {
-		siteId = tenant.SiteID
-	} else {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "invalid parameter"))
+	tenantConf, ok := config.Conf.Services.RailgunCDN.Tenants[appId]
+	if !ok {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid parameter"))
 		return
 	}
+	logging.AddFields(ctx, slog.String("app_id", appId), slog.String("object_path", objectPath))
+	tenant = newTenantBusinessData(appId, tenantConf)
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "client:Gateway", objectPath, start))
+	}()
 	timestampParsed, err := strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
-		c.JSON(consts.StatusBadRequest, common.APIResponseError(consts.StatusBadRequest, "invalid parameter"))
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid parameter"))
+		return
+	}
+	// URLs issued before issuance signing was introduced have no issue time, treat them as the oldest possible.
+	var issuedAtParsed int64
+	if issuedAt, issuanceSign := c.Query("i"), c.Query("v"); issuedAt != "" || issuanceSign != "" {
+		issuedAtParsed, err = strconv.ParseInt(issuedAt, 10, 64)
+		if err != nil || !api.VerifyIssuance(sign, issuedAtParsed, issuanceSign) {
+			common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid parameter"))
+			return
+		}
+	}
+	if revocation.IsRevoked(appId, objectPath, sign, issuedAtParsed) {
+		hlog.CtxInfof(ctx, "[RailgunCDN][Revoked] Method=%s", "ClientGateway")
+		outcome = gatewayOutcomeRevoked
+		common.RenderError(c, apierror.New(apierror.CodeRevoked, "the requested link has been revoked"))
 		return
 	}
 	publicURL := api.GetObjectPublicURL(appId, objectPath, sign, timestampParsed)
-	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s URI=%s", "ClientGateway", objectPath)
-	matomo.ReportEvent(ctx, matomo.Event{
-		SiteID:     siteId,
-		ActionName: "railgun_cdn:client:Gateway",
-		URL:        config.Conf.Services.RailgunCDN.CDN.Endpoint + objectPath,
-		UserAgent:  string(c.UserAgent()),
-		ClientIP:   c.ClientIP(),
-		ClientTime: time.Now(),
-	})
-	c.Redirect(consts.StatusMovedPermanently, []byte(publicURL))
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "ClientGateway")
+	outcome = gatewayOutcomeRedirect
+	// Not cached by clients and proxies, so that revocations apply to the next request
+	c.Response.Header.Set("Cache-Control", "no-store")
+	c.Redirect(consts.StatusFound, []byte(publicURL))
 }
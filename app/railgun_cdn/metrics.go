@@ -0,0 +1,31 @@
+package railgun_cdn
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	gatewayOutcomeRedirect = "redirect"
+	gatewayOutcomeRevoked  = "revoked"
+	gatewayOutcomeInvalid  = "invalid"
+
+	authFailureUnknownApp  = "unknown_app"
+	authFailureInvalidKey  = "invalid_key"
+	authFailureInvalidCert = "invalid_cert"
+)
+
+var (
+	uploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stargate_railgun_upload_bytes_total",
+		Help: "Number of bytes uploaded by tenants, including failed uploads.",
+	}, []string{"app_id"})
+	gatewayRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stargate_railgun_gateway_requests_total",
+		Help: "Number of client gateway requests, by tenant and outcome (redirect, revoked or invalid).",
+	}, []string{"app_id", "outcome"})
+	authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "stargate_railgun_auth_failures_total",
+		Help: "Number of tenant authorization failures, by reason (unknown_app, invalid_key or invalid_cert).",
+	}, []string{"reason"})
+)
@@ -0,0 +1,187 @@
+package railgun_cdn
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+)
+
+// InitiateUpload starts a multipart upload of an object, for objects too large to be uploaded in a single request.
+// The headers, user metadata and TTL of the object are given now, the object exists once the upload is completed.
+func InitiateUpload(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:InitiateUpload", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "InitiateUpload")
+	if tenantRequest.ObjectPath == "" {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
+		return
+	}
+	objectKey := tenant.RootPath + tenantRequest.ObjectPath
+	resp, err := api.InitiateUpload(ctx, objectKey, objectHeadersFromRequestContext(c), tenantRequest.TTL)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "InitiateUpload", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
+}
+
+// UploadPart uploads a part of a multipart upload. The Content-Length of the part is required.
+func UploadPart(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	body := &countingReader{r: c.RequestBodyStream()}
+	defer func() {
+		event := newEvent(c, tenant, "server:UploadPart", tenantRequest.ObjectPath, start)
+		event.BytesUploaded = body.n
+		reportEvent(ctx, c, tenant, event)
+		uploadBytes.WithLabelValues(tenant.AppID).Add(float64(body.n))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "UploadPart")
+	if tenantRequest.ObjectPath == "" {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
+		return
+	}
+	uploadID, err := uploadIDFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	partNumber, err := partNumberFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	size := int64(c.Request.Header.ContentLength())
+	if size <= 0 {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "parts must have a Content-Length and must not be empty"))
+		return
+	}
+	checksums, err := checksumsFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	objectKey := tenant.RootPath + tenantRequest.ObjectPath
+	resp, err := api.UploadPart(ctx, objectKey, uploadID, partNumber, body, size, checksums)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "UploadPart", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
+}
+
+// CompleteUpload assembles the uploaded parts of a multipart upload into the object.
+func CompleteUpload(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:CompleteUpload", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "CompleteUpload")
+	if tenantRequest.ObjectPath == "" {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
+		return
+	}
+	uploadID, err := uploadIDFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	checksums, err := checksumsFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	completeRequest := &CompleteUploadRequest{}
+	if err := c.BindJSON(completeRequest); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "invalid request body"))
+		return
+	}
+	if err := completeRequest.Validate(); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	objectKey := tenant.RootPath + tenantRequest.ObjectPath
+	resp, err := api.CompleteUpload(ctx, objectKey, uploadID, completeRequest.Parts, checksums)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "CompleteUpload", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	autoPurgeObject(ctx, tenant, tenantRequest.ObjectPath)
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(resp))
+}
+
+// AbortUpload cancels a multipart upload and deletes its uploaded parts.
+func AbortUpload(ctx context.Context, c *app.RequestContext) {
+	start := time.Now()
+	tenantRequest := &CommonTenantRequest{}
+	if err := tenantRequest.FromRequestContext(c); err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	tenant, err := authTenant(ctx, tenantRequest)
+	if err != nil {
+		common.RenderError(c, err)
+		return
+	}
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "server:AbortUpload", tenantRequest.ObjectPath, start))
+	}()
+	hlog.CtxInfof(ctx, "[RailgunCDN][Request] Method=%s", "AbortUpload")
+	if tenantRequest.ObjectPath == "" {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, "missing object path"))
+		return
+	}
+	uploadID, err := uploadIDFromRequestContext(c)
+	if err != nil {
+		common.RenderError(c, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	objectKey := tenant.RootPath + tenantRequest.ObjectPath
+	if err := api.AbortUpload(ctx, objectKey, uploadID); err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "AbortUpload", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	c.JSON(consts.StatusOK, common.APIResponseSuccess(nil))
+}
@@ -0,0 +1,182 @@
+package revocation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/json"
+)
+
+// TenantRevocations holds the revocation entries of a single tenant.
+type TenantRevocations struct {
+	Signs  map[string]int64 `json:"signs"`  // Revoked signs, mapped to the expiry time of the signed URL
+	Paths  map[string]int64 `json:"paths"`  // Revoked object paths, mapped to the cutoff issue time
+	Before int64            `json:"before"` // Links issued before this time are revoked
+}
+
+type Store struct {
+	path    string
+	maxTTL  int64 // Maximum lifespan of signed URLs in seconds
+	mutex   sync.RWMutex
+	tenants map[string]*TenantRevocations
+}
+
+var (
+	storeInstance *Store
+	once          sync.Once
+)
+
+// InitStore initializes the revocation store and loads the persisted entries from the given file.
+// Revoked object paths are kept until the URLs signed before the revocation, living at most maxTTL seconds, expire.
+// It should be called once, typically during application startup.
+func InitStore(path string, maxTTL int64) {
+	once.Do(func() {
+		store, err := loadStore(path, maxTTL)
+		if err != nil {
+			hlog.Fatalf("[Revocation] Error loading store: %v", err)
+		}
+		storeInstance = store
+	})
+}
+
+// loadStore creates a store persisted to the given file, with the entries already persisted to it if any.
+func loadStore(path string, maxTTL int64) (*Store, error) {
+	store := &Store{
+		path:    path,
+		maxTTL:  maxTTL,
+		tenants: make(map[string]*TenantRevocations),
+	}
+	if path == "" {
+		hlog.Warnf("[Revocation] No store path configured, revocations will not be persisted")
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.tenants); err != nil {
+		return nil, err
+	}
+	store.prune(time.Now().Unix())
+	hlog.Infof("[Revocation] Loaded revocations of %d tenants from %s", len(store.tenants), path)
+	return store, nil
+}
+
+// RevokeSign revokes a single signed URL by its sign until the URL expires.
+func RevokeSign(appID, sign string, expires int64) error {
+	return update(appID, func(t *TenantRevocations) {
+		t.Signs[sign] = expires
+	})
+}
+
+// RevokePath revokes all signed URLs of an object path issued up to now.
+func RevokePath(appID, objectPath string) error {
+	return update(appID, func(t *TenantRevocations) {
+		// Links issued within the current second are revoked as well.
+		t.Paths[objectPath] = time.Now().Unix() + 1
+	})
+}
+
+// RevokeBefore revokes all signed URLs of a tenant issued before the given time.
+func RevokeBefore(appID string, before int64) error {
+	return update(appID, func(t *TenantRevocations) {
+		if before > t.Before {
+			t.Before = before
+		}
+	})
+}
+
+// IsRevoked checks if a signed URL has been revoked.
+func IsRevoked(appID, objectPath, sign string, issuedAt int64) bool {
+	if storeInstance == nil {
+		return false
+	}
+	storeInstance.mutex.RLock()
+	defer storeInstance.mutex.RUnlock()
+
+	t, ok := storeInstance.tenants[appID]
+	if !ok {
+		return false
+	}
+	if issuedAt < t.Before {
+		return true
+	}
+	if cutoff, ok := t.Paths[objectPath]; ok && issuedAt < cutoff {
+		return true
+	}
+	_, ok = t.Signs[sign]
+	return ok
+}
+
+// update applies a change to the revocations of a tenant and persists the store.
+func update(appID string, change func(t *TenantRevocations)) error {
+	if storeInstance == nil {
+		return errors.New("revocation store is not initialized")
+	}
+	storeInstance.mutex.Lock()
+	defer storeInstance.mutex.Unlock()
+
+	t, ok := storeInstance.tenants[appID]
+	if !ok {
+		t = &TenantRevocations{}
+		storeInstance.tenants[appID] = t
+	}
+	if t.Signs == nil {
+		t.Signs = make(map[string]int64)
+	}
+	if t.Paths == nil {
+		t.Paths = make(map[string]int64)
+	}
+	change(t)
+	storeInstance.prune(time.Now().Unix())
+	return storeInstance.persist()
+}
+
+// prune removes the revoked signs and object paths of URLs that have already expired.
+func (s *Store) prune(now int64) {
+	for _, t := range s.tenants {
+		for sign, expires := range t.Signs {
+			if expires < now {
+				delete(t.Signs, sign)
+			}
+		}
+		for objectPath, cutoff := range t.Paths {
+			if cutoff+s.maxTTL < now {
+				delete(t.Paths, objectPath)
+			}
+		}
+	}
+}
+
+// persist writes the store to disk, replacing the previous file atomically.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.tenants)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // Ignore error, the file is gone after a successful rename
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
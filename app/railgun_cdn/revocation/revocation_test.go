@@ -0,0 +1,126 @@
+package revocation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useStore replaces the store with one persisted to path for the duration of a test.
+func useStore(t *testing.T, path string) {
+	t.Helper()
+	store, err := loadStore(path, 3600)
+	if err != nil {
+		t.Fatalf("loadStore: %v", err)
+	}
+	storeInstance = store
+	t.Cleanup(func() { storeInstance = nil })
+}
+
+// revokeAll revokes a sign and a path of app-a, and the links of app-b issued before before.
+func revokeAll(t *testing.T, now, before int64) {
+	t.Helper()
+	for _, err := range []error{
+		RevokeSign("app-a", "revoked-sign", now+600),
+		RevokePath("app-a", "/revoked.png"),
+		RevokeBefore("app-b", before),
+		RevokeBefore("app-b", before-1000), // Never moves the cutoff back
+	} {
+		if err != nil {
+			t.Fatalf("revoke: %v", err)
+		}
+	}
+}
+
+// checkRevoked checks IsRevoked against the revocations of revokeAll.
+func checkRevoked(t *testing.T, now, before int64) {
+	t.Helper()
+	for _, tc := range []struct {
+		name       string
+		appID      string
+		objectPath string
+		sign       string
+		issuedAt   int64
+		want       bool
+	}{
+		{"revoked sign", "app-a", "/a.png", "revoked-sign", now, true},
+		{"other sign", "app-a", "/a.png", "other-sign", now, false},
+		{"revoked sign of another tenant", "app-b", "/a.png", "revoked-sign", before, false},
+		{"revoked path issued before", "app-a", "/revoked.png", "other-sign", now - 10, true},
+		{"revoked path issued in the same second", "app-a", "/revoked.png", "other-sign", now, true},
+		{"revoked path issued after", "app-a", "/revoked.png", "other-sign", now + 2, false},
+		{"revoked path of another tenant", "app-b", "/revoked.png", "other-sign", before, false},
+		{"issued before", "app-b", "/a.png", "other-sign", before - 1, true},
+		{"issued at before", "app-b", "/a.png", "other-sign", before, false},
+		{"without issue time, before", "app-b", "/a.png", "other-sign", 0, true},
+		{"without issue time", "app-a", "/a.png", "other-sign", 0, false},
+		{"unknown tenant", "app-c", "/revoked.png", "revoked-sign", 0, false},
+	} {
+		if got := IsRevoked(tc.appID, tc.objectPath, tc.sign, tc.issuedAt); got != tc.want {
+			t.Errorf("%s: IsRevoked = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsRevoked(t *testing.T) {
+	if IsRevoked("app-a", "/a.png", "sign", 0) {
+		t.Error("revoked without store")
+	}
+	if err := RevokeSign("app-a", "sign", 0); err == nil {
+		t.Error("revoked without store")
+	}
+
+	useStore(t, "")
+	now := time.Now().Unix()
+	revokeAll(t, now, now-100)
+	checkRevoked(t, now, now-100)
+}
+
+func TestStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "revocations.json")
+	useStore(t, path)
+	now := time.Now().Unix()
+	revokeAll(t, now, now-100)
+
+	// Every update replaces the file, without leaving temporary files behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "revocations.json" {
+		t.Errorf("store directory holds %v, want only the store", entries)
+	}
+
+	useStore(t, path)
+	checkRevoked(t, now, now-100)
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStore(path, 3600); err == nil {
+		t.Error("corrupted store loaded")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := &Store{
+		maxTTL: 100,
+		tenants: map[string]*TenantRevocations{
+			"app-a": {
+				Signs: map[string]int64{"expired": 999, "live": 1000},
+				Paths: map[string]int64{"/expired.png": 899, "/live.png": 900},
+			},
+		},
+	}
+	s.prune(1000)
+
+	revocations := s.tenants["app-a"]
+	if _, ok := revocations.Signs["expired"]; ok || len(revocations.Signs) != 1 {
+		t.Errorf("signs = %v, want only the sign of the live URL", revocations.Signs)
+	}
+	if _, ok := revocations.Paths["/expired.png"]; ok || len(revocations.Paths) != 1 {
+		t.Errorf("paths = %v, want only the path whose URLs signed before the cutoff may still be live", revocations.Paths)
+	}
+}
@@ -0,0 +1,49 @@
+package railgun_cdn
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tundrawork/stargate/app/common/tracing"
+	"github.com/tundrawork/stargate/config"
+)
+
+func TestAuthTenantSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.InitWithExporter(exporter, "", nil)
+	t.Cleanup(func() { tracing.Shutdown(context.Background()) })
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {AppKey: "key-a", RootPath: "app-a"},
+	}
+
+	ctx := context.Background()
+	if _, err := authTenant(ctx, &CommonTenantRequest{AppID: "app-a", AppKey: "key-a"}); err != nil {
+		t.Fatalf("authTenant with a valid key: %v", err)
+	}
+	if _, err := authTenant(ctx, &CommonTenantRequest{AppID: "app-a", AppKey: "wrong"}); err == nil {
+		t.Fatal("authTenant with an invalid key succeeded")
+	}
+	tracing.ForceFlush(ctx)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2: %+v", len(spans), spans)
+	}
+	for _, span := range spans {
+		if span.Name != "railgun_cdn.authTenant" {
+			t.Errorf("span name = %q, want railgun_cdn.authTenant", span.Name)
+		}
+	}
+	authorized, rejected := spans[0], spans[1]
+	if !slices.Contains(authorized.Attributes, attribute.String("stargate.app_id", "app-a")) || authorized.Status.Code == codes.Error {
+		t.Errorf("authorized span: attributes %v, status %v", authorized.Attributes, authorized.Status)
+	}
+	if rejected.Status.Code != codes.Error || rejected.Status.Description != authFailureInvalidKey {
+		t.Errorf("rejected span status = %v, want error %s", rejected.Status, authFailureInvalidKey)
+	}
+}
@@ -1,17 +1,34 @@
 package railgun_cdn
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/tundrawork/stargate/app/common/tlsserver"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/config"
 )
 
+// metaHeaderPrefix is the prefix of request headers carrying user metadata of an object.
+const metaHeaderPrefix = "X-Meta-"
+
 type CommonTenantRequest struct {
 	AppID      string
 	AppKey     string
 	ObjectPath string
 	TTL        int64
+
+	clientCertNames []string // Names of the verified client certificate, if any
 }
 
 type GetURLResponse struct {
@@ -19,13 +36,54 @@ type GetURLResponse struct {
 	Expires int64  `json:"expires"`
 }
 
+type GetURLsRequest struct {
+	Objects []GetURLsRequestObject `json:"objects"`
+}
+
+type GetURLsRequestObject struct {
+	ObjectPath string `json:"objectPath"`
+	TTL        int64  `json:"ttl"`
+}
+
+type GetURLsResponse map[string]GetURLsResponseObject
+
+type GetURLsResponseObject struct {
+	URL     string `json:"url,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type CacheRequest struct {
+	ObjectPaths []string `json:"objectPaths"`
+	Directories []string `json:"directories"`
+}
+
+type CacheResponse struct {
+	TaskIDs []string `json:"taskIds"`
+}
+
+type CompleteUploadRequest struct {
+	Parts []api.UploadedPart `json:"parts"`
+}
+
+type RevokeRequest struct {
+	URL        string `json:"url"`
+	ObjectPath string `json:"objectPath"`
+	Before     int64  `json:"before"`
+
+	sign    string // Parsed from URL by Validate
+	expires int64  // Parsed from URL by Validate
+}
+
 // FromRequestContext extracts the common tenant request fields from the request context.
 func (req *CommonTenantRequest) FromRequestContext(c *app.RequestContext) error {
 	appID := c.GetHeader("X-App-Id")
 	appKey := c.GetHeader("X-App-Key")
 	objectPath := c.GetHeader("X-Object-Path")
+	clientCertNames := tlsserver.ClientCertificateNames(c)
 
-	if len(appID) == 0 || len(appKey) == 0 {
+	// A verified client certificate stands in for the app key
+	if len(appID) == 0 || (len(appKey) == 0 && len(clientCertNames) == 0) {
 		return errors.New("missing common tenant request fields")
 	}
 	if len(objectPath) > 0 && !isValidObjectPath(string(objectPath)) {
@@ -48,6 +106,174 @@ func (req *CommonTenantRequest) FromRequestContext(c *app.RequestContext) error
 	req.AppKey = string(appKey)
 	req.ObjectPath = string(objectPath)
 	req.TTL = ttl
+	req.clientCertNames = clientCertNames
+
+	return nil
+}
+
+// objectHeadersFromRequestContext extracts the object headers and user metadata to store from the request context.
+func objectHeadersFromRequestContext(c *app.RequestContext) api.ObjectHeaders {
+	headers := api.ObjectHeaders{
+		ContentType:        string(c.GetHeader("Content-Type")),
+		CacheControl:       string(c.GetHeader("Cache-Control")),
+		ContentDisposition: string(c.GetHeader("Content-Disposition")),
+		ContentEncoding:    string(c.GetHeader("Content-Encoding")),
+		ContentLanguage:    string(c.GetHeader("Content-Language")),
+	}
+	c.Request.Header.VisitAll(func(key, value []byte) {
+		if len(key) > len(metaHeaderPrefix) && strings.EqualFold(string(key[:len(metaHeaderPrefix)]), metaHeaderPrefix) {
+			if headers.Meta == nil {
+				headers.Meta = make(map[string]string)
+			}
+			headers.Meta[strings.ToLower(string(key[len(metaHeaderPrefix):]))] = string(value)
+		}
+	})
+	return headers
+}
+
+// checksumsFromRequestContext extracts the expected checksums of an uploaded object from the request context.
+func checksumsFromRequestContext(c *app.RequestContext) (api.Checksums, error) {
+	checksums := api.Checksums{}
+	if contentMD5 := c.GetHeader("Content-MD5"); len(contentMD5) > 0 {
+		sum, err := base64.StdEncoding.DecodeString(string(contentMD5))
+		if err != nil || len(sum) != md5.Size {
+			return api.Checksums{}, errors.New("invalid Content-MD5 value")
+		}
+		checksums.MD5 = sum
+	}
+	if checksumSHA256 := c.GetHeader("X-Checksum-SHA256"); len(checksumSHA256) > 0 {
+		sum, err := hex.DecodeString(string(checksumSHA256))
+		if err != nil || len(sum) != sha256.Size {
+			return api.Checksums{}, errors.New("invalid X-Checksum-SHA256 value")
+		}
+		checksums.SHA256 = sum
+	}
+	if checksumCRC64 := c.GetHeader("X-Checksum-CRC64"); len(checksumCRC64) > 0 {
+		sum, err := strconv.ParseUint(string(checksumCRC64), 10, 64)
+		if err != nil {
+			return api.Checksums{}, errors.New("invalid X-Checksum-CRC64 value")
+		}
+		checksums.CRC64 = strconv.FormatUint(sum, 10)
+	}
+	return checksums, nil
+}
+
+// uploadIDFromRequestContext extracts the ID of a multipart upload from the request context.
+// The ID is passed to COS in a query string, so it is restricted to the characters of the IDs issued by COS.
+func uploadIDFromRequestContext(c *app.RequestContext) (string, error) {
+	uploadID := string(c.GetHeader("X-Upload-Id"))
+	if uploadID == "" {
+		return "", errors.New("missing X-Upload-Id")
+	}
+	for _, r := range uploadID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return "", errors.New("invalid X-Upload-Id value")
+		}
+	}
+	return uploadID, nil
+}
 
+// partNumberFromRequestContext extracts the number of an uploaded part from the request context.
+func partNumberFromRequestContext(c *app.RequestContext) (int, error) {
+	partNumber, err := strconv.Atoi(string(c.GetHeader("X-Part-Number")))
+	if err != nil || partNumber < 1 || partNumber > api.MaxUploadParts {
+		return 0, fmt.Errorf("X-Part-Number must be between 1 and %d", api.MaxUploadParts)
+	}
+	return partNumber, nil
+}
+
+// Validate checks that the request lists every part once, in ascending order of part numbers.
+func (req *CompleteUploadRequest) Validate() error {
+	if len(req.Parts) == 0 {
+		return errors.New("missing parts")
+	}
+	for i, part := range req.Parts {
+		if part.PartNumber < 1 || part.PartNumber > api.MaxUploadParts {
+			return fmt.Errorf("part numbers must be between 1 and %d", api.MaxUploadParts)
+		}
+		if i > 0 && part.PartNumber <= req.Parts[i-1].PartNumber {
+			return errors.New("parts must be listed in ascending order of part numbers")
+		}
+		if part.ETag == "" {
+			return fmt.Errorf("missing etag of part %d", part.PartNumber)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the request has objects, within the batch size, each listed once as they are keyed by object
+// path in the response. Invalid object paths are reported per object instead.
+func (req *GetURLsRequest) Validate() error {
+	if len(req.Objects) == 0 {
+		return errors.New("missing objects")
+	}
+	if len(req.Objects) > maxBatchSize {
+		return errors.New("too many objects")
+	}
+	seen := make(map[string]bool, len(req.Objects))
+	for _, object := range req.Objects {
+		if seen[object.ObjectPath] {
+			return fmt.Errorf("duplicate object path %q", object.ObjectPath)
+		}
+		seen[object.ObjectPath] = true
+	}
+	return nil
+}
+
+// Validate checks that the cache request has valid targets, and directories only if they are allowed.
+func (req *CacheRequest) Validate(allowDirectories bool) error {
+	if len(req.ObjectPaths) == 0 && len(req.Directories) == 0 {
+		return errors.New("missing cache targets")
+	}
+	if len(req.ObjectPaths)+len(req.Directories) > maxBatchSize {
+		return errors.New("too many cache targets")
+	}
+	if !allowDirectories && len(req.Directories) > 0 {
+		return errors.New("directories are not supported")
+	}
+	for _, objectPath := range req.ObjectPaths {
+		if !isValidObjectPath(objectPath) {
+			return errors.New("invalid object path")
+		}
+	}
+	for _, directory := range req.Directories {
+		if !isValidDirectoryPath(directory) {
+			return errors.New("invalid directory path")
+		}
+	}
+	return nil
+}
+
+// Validate checks that the revoke request specifies at least one valid target of the tenant.
+func (req *RevokeRequest) Validate(appID string) error {
+	if req.URL == "" && req.ObjectPath == "" && req.Before == 0 {
+		return errors.New("missing revocation target")
+	}
+	if req.URL != "" {
+		signedURL, err := url.Parse(req.URL)
+		if err != nil {
+			return errors.New("invalid url")
+		}
+		query := signedURL.Query()
+		if query.Get("a") != appID || query.Get("s") == "" {
+			return errors.New("invalid url")
+		}
+		timestamp, err := strconv.ParseInt(query.Get("t"), 10, 64)
+		if err != nil {
+			return errors.New("invalid url")
+		}
+		req.sign = query.Get("s")
+		req.expires = timestamp - config.Conf.Services.RailgunCDN.CDN.TimestampOffset
+	}
+	if req.ObjectPath != "" && !isValidObjectPath(req.ObjectPath) {
+		return errors.New("invalid object path")
+	}
+	if req.Before < 0 {
+		return errors.New("before value must not be negative")
+	}
+	if req.Before > time.Now().Unix() {
+		// Would revoke the URLs signed until then, including ones not issued yet
+		return errors.New("before value must not be in the future")
+	}
 	return nil
 }
@@ -0,0 +1,125 @@
+package railgun_cdn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/config"
+)
+
+// getURLs requests the signed URLs of objects for tenant app-a, with a default TTL of ttl seconds if not empty.
+func getURLs(t *testing.T, body, ttl string) (int, GetURLsResponse, apierror.Code) {
+	t.Helper()
+	config.Conf.Services.RailgunCDN.Private.Endpoint = "https://stargate.example.com/railgun/v1/gateway"
+	config.Conf.Services.RailgunCDN.CDN.MaxTTL = 3600
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {AppKey: "key-a", RootPath: "app-a"},
+	}
+	t.Cleanup(func() { config.Conf.Services.RailgunCDN.CDN.MaxTTL = 0 })
+	h := server.New()
+	h.POST("/urls", GetURLs)
+
+	headers := []ut.Header{
+		{Key: "X-App-Id", Value: "app-a"},
+		{Key: "X-App-Key", Value: "key-a"},
+		{Key: "Content-Type", Value: "application/json"},
+	}
+	if ttl != "" {
+		headers = append(headers, ut.Header{Key: "X-TTL", Value: ttl})
+	}
+	w := ut.PerformRequest(h.Engine, http.MethodPost, "/urls", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)}, headers...)
+	var resp struct {
+		Data  GetURLsResponse `json:"data"`
+		Error *struct {
+			Code apierror.Code `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+	var code apierror.Code
+	if resp.Error != nil {
+		code = resp.Error.Code
+	}
+	return w.Code, resp.Data, code
+}
+
+// objects returns a request body listing the given object paths.
+func objects(paths ...string) string {
+	objects := make([]GetURLsRequestObject, len(paths))
+	for i, path := range paths {
+		objects[i].ObjectPath = path
+	}
+	data, _ := json.Marshal(GetURLsRequest{Objects: objects})
+	return string(data)
+}
+
+func TestGetURLsPerPathErrors(t *testing.T) {
+	body := `{"objects":[
+		{"objectPath":"/a.png","ttl":60},
+		{"objectPath":"/default-ttl.png"},
+		{"objectPath":"/too-long.png","ttl":7200},
+		{"objectPath":"/negative-ttl.png","ttl":-1},
+		{"objectPath":"no-slash.png"},
+		{"objectPath":"/dir/"}
+	]}`
+	status, resp, _ := getURLs(t, body, "300")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 as errors are reported per path", status)
+	}
+	for path, wantErr := range map[string]string{
+		"/a.png":            "",
+		"/default-ttl.png":  "",
+		"/too-long.png":     "ttl must not exceed 3600 seconds",
+		"/negative-ttl.png": "ttl must be a positive integer",
+		"no-slash.png":      "invalid object path",
+		"/dir/":             "invalid object path",
+	} {
+		object, ok := resp[path]
+		switch {
+		case !ok:
+			t.Errorf("%s: missing from the response", path)
+		case wantErr == "" && (object.Error != "" || !strings.Contains(object.URL, "o=%2F") || object.Expires == 0):
+			t.Errorf("%s: %+v, want a signed URL", path, object)
+		case wantErr != "" && (object.Error != wantErr || object.URL != ""):
+			t.Errorf("%s: %+v, want the error %q", path, object, wantErr)
+		}
+	}
+	if len(resp) != 6 {
+		t.Errorf("%d objects in the response, want 6", len(resp))
+	}
+}
+
+func TestGetURLsValidation(t *testing.T) {
+	full := make([]string, maxBatchSize)
+	for i := range full {
+		full[i] = fmt.Sprintf("/%d.png", i)
+	}
+	for name, tc := range map[string]struct {
+		body    string
+		status  int
+		code    apierror.Code
+		objects int
+	}{
+		"full batch":      {body: objects(full...), status: http.StatusOK, objects: maxBatchSize},
+		"too many":        {body: objects(append(full, "/extra.png")...), status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		"no objects":      {body: objects(), status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		"duplicate paths": {body: objects("/a.png", "/b.png", "/a.png"), status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		"invalid body":    {body: `{"objects":`, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			status, resp, code := getURLs(t, tc.body, "300")
+			if status != tc.status || code != tc.code || len(resp) != tc.objects {
+				t.Errorf("status %d, code %q, %d objects, want %d, %q, %d", status, code, len(resp), tc.status, tc.code, tc.objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package railgun_cdn
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/logging"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/config"
+)
+
+const (
+	defaultIndexDocument      = "index.html"
+	defaultWebsiteRedirectTTL = 5 * time.Minute
+)
+
+// websiteHosts maps the hosts of the tenant websites to the app IDs of their tenants.
+var websiteHosts map[string]config.RailgunCDNTenantAppID
+
+// websiteFile is the file serving a request to a website.
+type websiteFile struct {
+	objectPath string // Object path in the tenant's root path
+	status     int
+	metadata   api.HeadObjectResponse
+}
+
+// initWebsites indexes the hosts of the tenant websites, it exits if a website has no host or a host is claimed twice.
+func initWebsites() {
+	hosts, err := indexWebsiteHosts(config.Conf.Services.RailgunCDN.Tenants)
+	if err != nil {
+		hlog.Fatalf("[RailgunCDN][Website] %v", err)
+	}
+	websiteHosts = hosts
+}
+
+// indexWebsiteHosts maps the hosts of the enabled websites of tenants to the app IDs of the tenants.
+func indexWebsiteHosts(tenants map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant) (map[string]config.RailgunCDNTenantAppID, error) {
+	hosts := make(map[string]config.RailgunCDNTenantAppID)
+	for appID, tenant := range tenants {
+		if !tenant.Website.Enabled {
+			continue
+		}
+		if len(tenant.Website.Hosts) == 0 {
+			return nil, fmt.Errorf("website of tenant %s has no host", appID)
+		}
+		for _, host := range tenant.Website.Hosts {
+			host = strings.ToLower(host)
+			if other, ok := hosts[host]; ok {
+				return nil, fmt.Errorf("host %s is routed to the websites of both %s and %s", host, other, appID)
+			}
+			hosts[host] = appID
+		}
+	}
+	return hosts, nil
+}
+
+// WebsiteMiddleware serves the requests to the hosts of the tenant websites, and passes the other requests through.
+func WebsiteMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		appID, ok := websiteHosts[requestHost(c)]
+		if !ok {
+			c.Next(ctx)
+			return
+		}
+		serveWebsite(ctx, c, appID)
+		c.Abort()
+	}
+}
+
+// requestHost returns the lower-cased host of a request, without port.
+func requestHost(c *app.RequestContext) string {
+	host := string(c.Host())
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.ToLower(host)
+}
+
+// serveWebsite serves a request to the website of a tenant, through Stargate or by redirecting to the CDN.
+func serveWebsite(ctx context.Context, c *app.RequestContext, appID string) {
+	start := time.Now()
+	tenantConf := config.Conf.Services.RailgunCDN.Tenants[appID]
+	tenant := newTenantBusinessData(appID, tenantConf)
+	site := tenantConf.Website
+	requestPath := string(c.Path())
+	logging.AddFields(ctx, slog.String("app_id", appID), slog.String("object_path", requestPath))
+	defer func() {
+		reportEvent(ctx, c, tenant, newEvent(c, tenant, "website:Get", requestPath, start))
+	}()
+	if !c.IsGet() && !c.IsHead() {
+		c.Response.Header.Set("Allow", "GET, HEAD")
+		c.SetStatusCode(consts.StatusMethodNotAllowed)
+		return
+	}
+	file, location, err := resolveWebsiteFile(ctx, tenant, site, requestPath)
+	if err != nil {
+		if !api.IsNotFound(err) {
+			hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "Website", err.Error())
+		}
+		common.RenderError(c, err)
+		return
+	}
+	if location != "" {
+		if query := c.Request.URI().QueryString(); len(query) > 0 {
+			location += "?" + string(query)
+		}
+		c.Redirect(consts.StatusMovedPermanently, []byte(location))
+		return
+	}
+	if file.status == consts.StatusOK && ifNoneMatch(c, common.FromPtr(file.metadata.ETag)) {
+		setWebsiteHeaders(c, site, file.metadata)
+		c.SetStatusCode(consts.StatusNotModified)
+		return
+	}
+	// The error document is always served through Stargate, so that its status is kept.
+	if site.Redirect && file.status == consts.StatusOK {
+		publicURL, err := websitePublicURL(tenant, site, file.objectPath)
+		if err != nil {
+			common.RenderError(c, err)
+			return
+		}
+		c.Redirect(consts.StatusFound, []byte(publicURL))
+		return
+	}
+	objectKey := tenant.RootPath + file.objectPath
+	if c.IsHead() {
+		setWebsiteHeaders(c, site, file.metadata)
+		c.Response.Header.SetContentLength(int(common.FromPtr(file.metadata.ContentLength)))
+		c.SetStatusCode(file.status)
+		return
+	}
+	body, metadata, err := api.GetObject(ctx, objectKey)
+	if err != nil {
+		hlog.CtxErrorf(ctx, "[RailgunCDN][Error] Method=%s Error=%s", "Website", err.Error())
+		common.RenderError(c, err)
+		return
+	}
+	setWebsiteHeaders(c, site, metadata)
+	c.SetStatusCode(file.status)
+	c.Response.SetBodyStream(body, int(common.FromPtr(metadata.ContentLength))) // Closed by the server once written
+}
+
+// resolveWebsiteFile resolves the file serving a request path: the requested file, the index document of a directory,
+// the index document of the website root as SPA fallback, or the error document.
+// A directory requested without trailing slash resolves to a redirect location instead.
+func resolveWebsiteFile(ctx context.Context, tenant *TenantBusinessData, site config.RailgunCDNTenantWebsite, requestPath string) (_ websiteFile, location string, err error) {
+	indexDocument := site.IndexDocument
+	if indexDocument == "" {
+		indexDocument = defaultIndexDocument
+	}
+	directory := strings.HasSuffix(requestPath, "/")
+	candidates := []struct {
+		filePath string
+		status   int
+		redirect bool // Redirects to the directory with trailing slash if the file exists
+		enabled  bool
+	}{
+		{requestPath, consts.StatusOK, false, !directory},
+		{requestPath + indexDocument, consts.StatusOK, false, directory},
+		{requestPath + "/" + indexDocument, consts.StatusOK, true, !directory},
+		{"/" + indexDocument, consts.StatusOK, false, site.SPAFallback && path.Ext(requestPath) == ""},
+		{site.ErrorDocument, consts.StatusNotFound, false, site.ErrorDocument != ""},
+	}
+	for _, candidate := range candidates {
+		if !candidate.enabled {
+			continue
+		}
+		objectPath := site.Prefix + candidate.filePath
+		metadata, err := api.HeadObject(ctx, tenant.RootPath+objectPath)
+		if api.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return websiteFile{}, "", err
+		}
+		if candidate.redirect {
+			return websiteFile{}, requestPath + "/", nil
+		}
+		return websiteFile{objectPath: objectPath, status: candidate.status, metadata: metadata}, "", nil
+	}
+	return websiteFile{}, "", apierror.New(apierror.CodeNotFound, "The requested file does not exist.")
+}
+
+// ifNoneMatch reports whether the If-None-Match header of a request matches the ETag of an object.
+func ifNoneMatch(c *app.RequestContext, eTag string) bool {
+	for _, tag := range strings.Split(string(c.GetHeader("If-None-Match")), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || (eTag != "" && tag == `"`+eTag+`"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// setWebsiteHeaders sets the response headers of a website file from its metadata.
+func setWebsiteHeaders(c *app.RequestContext, site config.RailgunCDNTenantWebsite, metadata api.HeadObjectResponse) {
+	header := &c.Response.Header
+	if eTag := common.FromPtr(metadata.ETag); eTag != "" {
+		header.Set("ETag", `"`+eTag+`"`)
+	}
+	cacheControl := common.FromPtr(metadata.CacheControl)
+	if cacheControl == "" {
+		cacheControl = site.CacheControl
+	}
+	for key, value := range map[string]string{
+		"Content-Type":        common.FromPtr(metadata.ContentType),
+		"Last-Modified":       common.FromPtr(metadata.LastModified),
+		"Cache-Control":       cacheControl,
+		"Content-Encoding":    common.FromPtr(metadata.ContentEncoding),
+		"Content-Language":    common.FromPtr(metadata.ContentLanguage),
+		"Content-Disposition": common.FromPtr(metadata.ContentDisposition),
+	} {
+		if value != "" {
+			header.Set(key, value)
+		}
+	}
+}
+
+// websitePublicURL signs a short-lived public CDN URL of a website file.
+func websitePublicURL(tenant *TenantBusinessData, site config.RailgunCDNTenantWebsite, objectPath string) (string, error) {
+	ttl := time.Duration(site.RedirectTTLMs) * time.Millisecond
+	if ttl <= 0 {
+		ttl = defaultWebsiteRedirectTTL
+	}
+	sign, timestamp, _, err := api.SignObject("/"+tenant.RootPath+objectPath, min(max(int64(ttl/time.Second), 1), api.MaxTTL()))
+	if err != nil {
+		return "", err
+	}
+	return api.GetObjectPublicURL(tenant.AppID, objectPath, sign, timestamp), nil
+}
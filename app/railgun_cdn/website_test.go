@@ -0,0 +1,151 @@
+package railgun_cdn
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api/costest"
+	"github.com/tundrawork/stargate/config"
+)
+
+// newWebsiteServer serves the websites of app-a, with SPA fallback and an error document, and of app-b, without,
+// from an in-memory bucket holding their files. Other hosts are served "api".
+func newWebsiteServer(t *testing.T) *server.Hertz {
+	t.Helper()
+	bucket := costest.NewBucket()
+	t.Cleanup(bucket.Close)
+	api.SetCosClient(bucket.URL(), bucket.Client())
+	config.Conf.Services.RailgunCDN.CDN.Endpoint = "https://cdn.example.com"
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {RootPath: "app-a", Website: config.RailgunCDNTenantWebsite{
+			Enabled:       true,
+			Hosts:         []string{"Site-A.example.com"},
+			Prefix:        "/site",
+			ErrorDocument: "/404.html",
+			SPAFallback:   true,
+			CacheControl:  "public, max-age=300",
+		}},
+		"app-b": {RootPath: "app-b", Website: config.RailgunCDNTenantWebsite{
+			Enabled:  true,
+			Hosts:    []string{"site-b.example.com"},
+			Redirect: true,
+		}},
+	}
+	initWebsites()
+	t.Cleanup(func() { websiteHosts = nil })
+	for key, content := range map[string]string{
+		"app-a/site/index.html":      "root index",
+		"app-a/site/about.html":      "about",
+		"app-a/site/docs/index.html": "docs index",
+		"app-a/site/404.html":        "not found",
+		"app-b/index.html":           "b index",
+	} {
+		if _, err := api.PutObject(context.Background(), key, strings.NewReader(content), api.ObjectHeaders{ContentType: "text/html"}, api.Checksums{}, 0); err != nil {
+			t.Fatalf("PutObject(%q): %v", key, err)
+		}
+	}
+
+	h := server.New()
+	h.Use(WebsiteMiddleware())
+	h.Any("/*path", func(_ context.Context, c *app.RequestContext) {
+		c.String(http.StatusOK, "api")
+	})
+	return h
+}
+
+func TestWebsite(t *testing.T) {
+	h := newWebsiteServer(t)
+	aboutETag := md5.Sum([]byte("about"))
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		host     string
+		target   string
+		headers  []ut.Header
+		status   int
+		body     string
+		location string
+	}{
+		{name: "file", host: "site-a.example.com", target: "/about.html", status: http.StatusOK, body: "about"},
+		{name: "host with port and case", host: "SITE-A.example.com:8080", target: "/about.html", status: http.StatusOK, body: "about"},
+		{name: "root index", host: "site-a.example.com", target: "/", status: http.StatusOK, body: "root index"},
+		{name: "directory index", host: "site-a.example.com", target: "/docs/", status: http.StatusOK, body: "docs index"},
+		{name: "directory without slash", host: "site-a.example.com", target: "/docs?lang=en", status: http.StatusMovedPermanently, location: "/docs/?lang=en"},
+		{name: "spa fallback", host: "site-a.example.com", target: "/app/route", status: http.StatusOK, body: "root index"},
+		{name: "no spa fallback with extension", host: "site-a.example.com", target: "/missing.png", status: http.StatusNotFound, body: "not found"},
+		{name: "spa fallback of missing directory", host: "site-a.example.com", target: "/missing/", status: http.StatusOK, body: "root index"},
+		{name: "not modified", host: "site-a.example.com", target: "/about.html",
+			headers: []ut.Header{{Key: "If-None-Match", Value: `"other", W/"` + hex.EncodeToString(aboutETag[:]) + `"`}}, status: http.StatusNotModified},
+		{name: "not modified by any", host: "site-a.example.com", target: "/about.html",
+			headers: []ut.Header{{Key: "If-None-Match", Value: "*"}}, status: http.StatusNotModified},
+		{name: "modified", host: "site-a.example.com", target: "/about.html",
+			headers: []ut.Header{{Key: "If-None-Match", Value: `"other"`}}, status: http.StatusOK, body: "about"},
+		{name: "head", method: http.MethodHead, host: "site-a.example.com", target: "/about.html", status: http.StatusOK},
+		{name: "method not allowed", method: http.MethodPost, host: "site-a.example.com", target: "/about.html", status: http.StatusMethodNotAllowed},
+		{name: "redirect to cdn", host: "site-b.example.com", target: "/", status: http.StatusFound, location: "https://cdn.example.com/app-b/index.html?sign="},
+		{name: "missing without fallback", host: "site-b.example.com", target: "/missing", status: http.StatusNotFound, body: `"NOT_FOUND"`},
+		{name: "other host", host: "api.example.com", target: "/about.html", status: http.StatusOK, body: "api"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := ut.PerformRequest(h.Engine, method, "http://"+tc.host+tc.target, nil, tc.headers...)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tc.body) {
+				t.Errorf("body = %q, want %q", w.Body.String(), tc.body)
+			}
+			if location := w.Header().Get("Location"); !strings.HasPrefix(location, tc.location) || (tc.location == "") != (location == "") {
+				t.Errorf("Location = %q, want %q", location, tc.location)
+			}
+			switch tc.status {
+			case http.StatusOK, http.StatusNotModified:
+				if tc.host == "site-a.example.com" && w.Header().Get("Cache-Control") != "public, max-age=300" {
+					t.Errorf("Cache-Control = %q, want the default of the website", w.Header().Get("Cache-Control"))
+				}
+			case http.StatusMethodNotAllowed:
+				if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+					t.Errorf("Allow = %q", allow)
+				}
+			}
+			if method == http.MethodHead && w.Header().Get("Content-Length") != "5" {
+				t.Errorf("Content-Length = %q, want the length of the file", w.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestIndexWebsiteHosts(t *testing.T) {
+	website := func(hosts ...string) config.RailgunCDNTenant {
+		return config.RailgunCDNTenant{Website: config.RailgunCDNTenantWebsite{Enabled: true, Hosts: hosts}}
+	}
+	hosts, err := indexWebsiteHosts(map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": website("A.example.com", "www.a.example.com"),
+		"app-b": {Website: config.RailgunCDNTenantWebsite{Hosts: []string{"a.example.com"}}}, // Disabled
+	})
+	if err != nil || len(hosts) != 2 || hosts["a.example.com"] != "app-a" || hosts["www.a.example.com"] != "app-a" {
+		t.Errorf("indexWebsiteHosts = %v, %v, want the hosts of the enabled website, lower-cased", hosts, err)
+	}
+
+	for name, tenants := range map[string]map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"duplicate host": {"app-a": website("a.example.com"), "app-b": website("A.example.com")},
+		"no host":        {"app-a": website()},
+	} {
+		if _, err := indexWebsiteHosts(tenants); err == nil {
+			t.Errorf("%s: indexWebsiteHosts accepted the websites", name)
+		}
+	}
+}
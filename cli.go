@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+
+	"github.com/tundrawork/stargate/pkg/railgunclient"
+)
+
+// Exit codes of the tenant commands.
+const (
+	exitOK           = 0
+	exitError        = 1 // Any other error
+	exitUsage        = 2 // Invalid command line
+	exitNotFound     = 3 // The object does not exist
+	exitUnauthorized = 4 // The credentials were rejected
+	exitPartial      = 5 // Some objects of a command on multiple objects failed
+)
+
+// commands are the subcommands of the stargate binary, running the server is the default.
+var commands = map[string]func(args []string) int{
+	"replay-accesslog": replayAccessLog,
+	"ls":               listObjects,
+	"stat":             statObject,
+	"put":              putObjects,
+	"get":              getObject,
+	"rm":               removeObjects,
+	"sign":             signObjects,
+	"sync":             syncObjects,
+}
+
+// profile holds the credentials of a tenant, from a profile file or the environment.
+type profile struct {
+	Endpoint string `yaml:"Endpoint"` // URL of the Stargate server
+	AppID    string `yaml:"AppID"`
+	AppKey   string `yaml:"AppKey"`   // May be empty when authenticating with a client certificate
+	CertFile string `yaml:"CertFile"` // Client certificate for mutual TLS
+	KeyFile  string `yaml:"KeyFile"`
+	CAFile   string `yaml:"CAFile"` // CAs verifying the server, defaults to the system ones
+}
+
+// clientFlags registers the flags selecting the credentials of a command.
+// The returned function builds the client once the flags are parsed.
+func clientFlags(flags *flag.FlagSet) func() (*railgunclient.Client, error) {
+	profileName := flags.String("profile", "", "profile of the credentials file, default $STARGATE_PROFILE or \"default\"")
+	return func() (*railgunclient.Client, error) {
+		p, err := loadProfile(*profileName)
+		if err != nil {
+			return nil, err
+		}
+		return p.client()
+	}
+}
+
+// loadProfile loads a profile from the credentials file, $STARGATE_CREDENTIALS or ~/.config/stargate/credentials.yaml,
+// overridden by the STARGATE_ENDPOINT, STARGATE_APP_ID and STARGATE_APP_KEY environment variables.
+// The file is optional if the environment provides the credentials.
+func loadProfile(name string) (profile, error) {
+	if name == "" {
+		name = os.Getenv("STARGATE_PROFILE")
+	}
+	explicit := name != ""
+	if name == "" {
+		name = "default"
+	}
+	path := os.Getenv("STARGATE_CREDENTIALS")
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "stargate", "credentials.yaml")
+		}
+	}
+
+	var p profile
+	if path != "" {
+		k := koanf.New(".")
+		if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return profile{}, fmt.Errorf("error loading credentials file: %w", err)
+			}
+		} else if err := k.Unmarshal(name, &p); err != nil {
+			return profile{}, fmt.Errorf("error loading profile %s: %w", name, err)
+		}
+		if explicit && !k.Exists(name) {
+			return profile{}, fmt.Errorf("profile %s not found in %s", name, path)
+		}
+	}
+	for env, field := range map[string]*string{
+		"STARGATE_ENDPOINT": &p.Endpoint,
+		"STARGATE_APP_ID":   &p.AppID,
+		"STARGATE_APP_KEY":  &p.AppKey,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+	if p.Endpoint == "" || p.AppID == "" || (p.AppKey == "" && p.CertFile == "") {
+		return profile{}, errors.New("missing credentials, set STARGATE_ENDPOINT, STARGATE_APP_ID and STARGATE_APP_KEY or use a profile")
+	}
+	return p, nil
+}
+
+func (p profile) client() (*railgunclient.Client, error) {
+	if p.CertFile == "" && p.CAFile == "" {
+		return railgunclient.New(p.Endpoint, p.AppID, p.AppKey), nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if p.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", p.CAFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return railgunclient.New(p.Endpoint, p.AppID, p.AppKey,
+		railgunclient.WithHTTPClient(&http.Client{Transport: transport}),
+	), nil
+}
+
+// exitCode reports err on stderr, prefixed with the command name, and returns the matching exit code.
+func exitCode(command string, err error) int {
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+	}
+	return codeOf(err)
+}
+
+// codeOf returns the exit code matching err.
+func codeOf(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case railgunclient.IsNotFound(err), errors.Is(err, os.ErrNotExist):
+		return exitNotFound
+	case railgunclient.IsCode(err, railgunclient.CodeUnauthorized):
+		return exitUnauthorized
+	default:
+		return exitError
+	}
+}
+
+// usage sets the usage message of a command.
+func usage(flags *flag.FlagSet, synopsis string) {
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: stargate %s %s\n", flags.Name(), synopsis)
+		flags.PrintDefaults()
+	}
+}
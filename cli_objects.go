@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tundrawork/stargate/pkg/railgunclient"
+)
+
+// listObjects implements the ls command, which lists the objects of the tenant under a prefix.
+func listObjects(args []string) int {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	long := flags.Bool("l", false, "print the size, last modified time and ETag of objects")
+	usage(flags, "[flags] [PREFIX]")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("ls", err)
+	}
+	prefix := flags.Arg(0)
+	objects, err := client.List(context.Background(), prefix, false)
+	if err != nil {
+		return exitCode("ls", err)
+	}
+	for _, objectPath := range sortedPaths(objects, prefix) {
+		if *long {
+			metadata := objects[objectPath]
+			fmt.Printf("%12d  %s  %s  %s\n", metadata.ContentLength, metadata.LastModified, metadata.ETag, objectPath)
+		} else {
+			fmt.Println(objectPath)
+		}
+	}
+	return exitOK
+}
+
+// statObject implements the stat command, which prints the metadata of an object as JSON.
+func statObject(args []string) int {
+	flags := flag.NewFlagSet("stat", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	usage(flags, "[flags] PATH")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("stat", err)
+	}
+	metadata, err := client.Head(context.Background(), flags.Arg(0))
+	if err != nil {
+		return exitCode("stat", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return exitCode("stat", encoder.Encode(metadata))
+}
+
+// getObject implements the get command, which downloads an object to a file or to the standard output.
+func getObject(args []string) int {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	usage(flags, "[flags] PATH [FILE|-]")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("get", err)
+	}
+	objectPath := flags.Arg(0)
+	filename := flags.Arg(1)
+	if filename == "" {
+		filename = path.Base(objectPath)
+	}
+	body, err := client.Get(context.Background(), objectPath)
+	if err != nil {
+		return exitCode("get", err)
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close() // Ignore error
+	}(body)
+	if filename == "-" {
+		_, err = io.Copy(os.Stdout, body)
+		return exitCode("get", err)
+	}
+	// Download to a temporary file first, so that a failed download does not leave a truncated file behind
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".stargate-*")
+	if err != nil {
+		return exitCode("get", err)
+	}
+	defer func(name string) {
+		_ = os.Remove(name) // Ignore error, the file is gone once renamed
+	}(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		return exitCode("get", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return exitCode("get", err)
+	}
+	return exitCode("get", os.Rename(tmp.Name(), filename))
+}
+
+// removeObjects implements the rm command, which deletes objects, or directories of objects with -r.
+func removeObjects(args []string) int {
+	flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	recursive := flags.Bool("r", false, "delete the given paths and all objects under them as directories")
+	concurrency := flags.Int("j", 8, "number of concurrent deletions")
+	quiet := flags.Bool("q", false, "do not print progress")
+	usage(flags, "[flags] PATH...")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 || *concurrency <= 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("rm", err)
+	}
+	ctx := context.Background()
+	objectPaths := flags.Args()
+	if *recursive {
+		objectPaths = nil
+		for _, dir := range flags.Args() {
+			objects, err := client.List(ctx, strings.TrimSuffix(dir, "/"), false)
+			if err != nil {
+				return exitCode("rm", err)
+			}
+			objectPaths = append(objectPaths, directoryPaths(objects, dir)...)
+		}
+	}
+	progress := newProgress("rm", len(objectPaths), *quiet)
+	runConcurrently(objectPaths, *concurrency, func(objectPath string) {
+		progress.done(objectPath, 0, client.Delete(ctx, objectPath))
+	})
+	return progress.exitCode()
+}
+
+// signObjects implements the sign command, which prints signed URLs of objects.
+func signObjects(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	ttl := flags.Duration("ttl", time.Hour, "lifespan of the URLs")
+	usage(flags, "[flags] PATH...")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 || *ttl < time.Second {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("sign", err)
+	}
+	ctx := context.Background()
+	if flags.NArg() == 1 {
+		signed, err := client.Sign(ctx, flags.Arg(0), *ttl)
+		if err != nil {
+			return exitCode("sign", err)
+		}
+		fmt.Println(signed.URL)
+		return exitOK
+	}
+	requests := make([]railgunclient.SignRequest, flags.NArg())
+	for i, objectPath := range flags.Args() {
+		requests[i] = railgunclient.SignRequest{ObjectPath: objectPath}
+	}
+	results, err := client.SignBatch(ctx, requests, *ttl)
+	if err != nil {
+		return exitCode("sign", err)
+	}
+	code := exitOK
+	for _, objectPath := range flags.Args() {
+		result := results[objectPath]
+		if result.Error != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "sign: %s: %s\n", objectPath, result.Error)
+			code = exitPartial
+			continue
+		}
+		fmt.Printf("%s\t%s\n", objectPath, result.URL)
+	}
+	return code
+}
+
+// sortedPaths returns the paths of the objects under prefix, sorted.
+func sortedPaths(objects map[string]railgunclient.ObjectMetadata, prefix string) []string {
+	var paths []string
+	for objectPath := range objects {
+		if strings.HasPrefix(objectPath, prefix) {
+			paths = append(paths, objectPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// directoryPaths returns the paths of the objects in the directory dir and its subdirectories, sorted, and dir itself
+// if it is an object. Unlike a plain prefix, "/img" matches "/img/a.png" but not "/images/a.png".
+func directoryPaths(objects map[string]railgunclient.ObjectMetadata, dir string) []string {
+	dir = strings.TrimSuffix(dir, "/")
+	var paths []string
+	for objectPath := range objects {
+		if objectPath == dir || strings.HasPrefix(objectPath, dir+"/") {
+			paths = append(paths, objectPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// runConcurrently calls fn for every item, with at most concurrency calls at a time.
+func runConcurrently(items []string, concurrency int, fn func(item string)) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for _, item := range items {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// progress reports the progress of a command on multiple objects on stderr.
+type progress struct {
+	command string
+	total   int
+	quiet   bool
+
+	mutex    sync.Mutex
+	finished int
+	failed   int
+	bytes    int64
+	errs     []error
+	start    time.Time
+}
+
+func newProgress(command string, total int, quiet bool) *progress {
+	return &progress{
+		command: command,
+		total:   total,
+		quiet:   quiet,
+		start:   time.Now(),
+	}
+}
+
+// done records the outcome of an object.
+func (p *progress) done(item string, size int64, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.finished++
+	if err != nil {
+		p.failed++
+		p.errs = append(p.errs, err)
+		_, _ = fmt.Fprintf(os.Stderr, "%s: [%d/%d] %s: %v\n", p.command, p.finished, p.total, item, err)
+		return
+	}
+	p.bytes += size
+	if !p.quiet {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: [%d/%d] %s\n", p.command, p.finished, p.total, item)
+	}
+}
+
+// exitCode prints the summary and returns the exit code of the command.
+func (p *progress) exitCode() int {
+	if !p.quiet {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %d done, %d failed, %d bytes in %s\n",
+			p.command, p.finished-p.failed, p.failed, p.bytes, time.Since(p.start).Round(time.Millisecond))
+	}
+	switch {
+	case p.failed == 0:
+		return exitOK
+	case p.failed < p.total:
+		return exitPartial
+	default:
+		// Everything failed, likely for the same reason
+		return codeOf(p.errs[0])
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tundrawork/stargate/pkg/railgunclient"
+)
+
+func TestDirectoryPaths(t *testing.T) {
+	objects := map[string]railgunclient.ObjectMetadata{
+		"/img":           {},
+		"/img/a.png":     {},
+		"/img/sub/b.png": {},
+		"/images/c.png":  {},
+		"/imgx":          {},
+	}
+	for dir, want := range map[string][]string{
+		"/img":    {"/img", "/img/a.png", "/img/sub/b.png"},
+		"/img/":   {"/img", "/img/a.png", "/img/sub/b.png"},
+		"/img/a":  nil,
+		"/images": {"/images/c.png"},
+		"/":       {"/images/c.png", "/img", "/img/a.png", "/img/sub/b.png", "/imgx"},
+	} {
+		if got := directoryPaths(objects, dir); !slices.Equal(got, want) {
+			t.Errorf("directoryPaths(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tundrawork/stargate/pkg/railgunclient"
+)
+
+// uploadFlags are the flags of the commands uploading files.
+type uploadFlags struct {
+	concurrency  *int
+	quiet        *bool
+	ttl          *time.Duration
+	contentType  *string
+	cacheControl *string
+}
+
+func newUploadFlags(flags *flag.FlagSet) uploadFlags {
+	return uploadFlags{
+		concurrency:  flags.Int("j", 4, "number of concurrent uploads"),
+		quiet:        flags.Bool("q", false, "do not print progress"),
+		ttl:          flags.Duration("ttl", 0, "lifespan of the uploaded objects, 0 keeps them forever"),
+		contentType:  flags.String("content-type", "", "content type of the objects, guessed from the file extension by default"),
+		cacheControl: flags.String("cache-control", "", "Cache-Control header stored with the objects"),
+	}
+}
+
+// putObjects implements the put command, which uploads a file, the standard input, or a directory tree with -r.
+func putObjects(args []string) int {
+	flags := flag.NewFlagSet("put", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	recursive := flags.Bool("r", false, "upload the directory SOURCE recursively under the prefix DEST")
+	upload := newUploadFlags(flags)
+	usage(flags, "[flags] SOURCE|- DEST")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 || *upload.concurrency <= 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	source, dest := flags.Arg(0), flags.Arg(1)
+	if !strings.HasPrefix(dest, "/") {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("put", err)
+	}
+	ctx := context.Background()
+
+	if !*recursive {
+		if source == "-" && strings.HasSuffix(dest, "/") {
+			flags.Usage()
+			return exitUsage
+		}
+		if strings.HasSuffix(dest, "/") {
+			dest += filepath.Base(source)
+		}
+		if source == "-" {
+			_, err = client.Put(ctx, dest, os.Stdin, upload.options(dest, 0))
+			return exitCode("put", err)
+		}
+		_, err = uploadFile(ctx, client, upload, source, dest)
+		return exitCode("put", err)
+	}
+
+	files, err := railgunclient.LocalFiles(source)
+	if err != nil {
+		return exitCode("put", err)
+	}
+	prefix := strings.TrimSuffix(dest, "/")
+	uploads := make([]string, 0, len(files))
+	for relPath := range files {
+		uploads = append(uploads, relPath)
+	}
+	sort.Strings(uploads)
+	progress := newProgress("put", len(uploads), *upload.quiet)
+	runConcurrently(uploads, *upload.concurrency, func(relPath string) {
+		size, err := uploadFile(ctx, client, upload, files[relPath], prefix+relPath)
+		progress.done(prefix+relPath, size, err)
+	})
+	return progress.exitCode()
+}
+
+// syncObjects implements the sync command, which makes the objects under a prefix match a local directory tree.
+func syncObjects(args []string) int {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	newClient := clientFlags(flags)
+	upload := newUploadFlags(flags)
+	compare := flags.String("compare", string(railgunclient.CompareETag), "how files of the same size are compared: etag, crc64 (slower) or size")
+	deleteExtraneous := flags.Bool("delete", false, "delete objects under PREFIX without a local file")
+	dryRun := flags.Bool("dry-run", false, "print the uploads and deletions without doing them")
+	usage(flags, "[flags] DIR PREFIX")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	switch railgunclient.SyncCompare(*compare) {
+	case railgunclient.CompareETag, railgunclient.CompareCRC64, railgunclient.CompareSize:
+	default:
+		flags.Usage()
+		return exitUsage
+	}
+	if flags.NArg() != 2 || *upload.concurrency <= 0 || !strings.HasPrefix(flags.Arg(1), "/") {
+		flags.Usage()
+		return exitUsage
+	}
+	client, err := newClient()
+	if err != nil {
+		return exitCode("sync", err)
+	}
+
+	ctx := context.Background()
+	opts := railgunclient.SyncOptions{
+		Compare:     railgunclient.SyncCompare(*compare),
+		Delete:      *deleteExtraneous,
+		Concurrency: *upload.concurrency,
+		Options: func(objectPath string) railgunclient.PutOptions {
+			return upload.options(objectPath, 0)
+		},
+	}
+	plan, err := client.PlanSync(ctx, flags.Arg(0), flags.Arg(1), opts)
+	if err != nil {
+		return exitCode("sync", err)
+	}
+	if *dryRun {
+		for _, action := range plan.Actions {
+			fmt.Printf("%s %s (%s)\n", actionVerb(action), action.ObjectPath, action.Reason)
+		}
+		fmt.Printf("%d to upload or delete, %d unchanged\n", len(plan.Actions), plan.Unchanged)
+		return exitOK
+	}
+	progress := newProgress("sync", len(plan.Actions), *upload.quiet)
+	opts.Done = func(action railgunclient.SyncAction) {
+		progress.done(actionVerb(action)+" "+action.ObjectPath, action.Size, action.Err)
+	}
+	_ = client.ApplySync(ctx, plan, opts) // Errors are reported by progress
+	if !*upload.quiet {
+		_, _ = fmt.Fprintf(os.Stderr, "sync: %d unchanged\n", plan.Unchanged)
+	}
+	return progress.exitCode()
+}
+
+// actionVerb describes a sync action.
+func actionVerb(action railgunclient.SyncAction) string {
+	if action.Delete {
+		return "delete"
+	}
+	return "upload"
+}
+
+// options returns the upload options of an object.
+func (u uploadFlags) options(objectPath string, crc uint64) railgunclient.PutOptions {
+	contentType := *u.contentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(objectPath))
+	}
+	return railgunclient.PutOptions{
+		ObjectHeaders: railgunclient.ObjectHeaders{
+			ContentType:  contentType,
+			CacheControl: *u.cacheControl,
+		},
+		TTL:   int64(*u.ttl / time.Second),
+		CRC64: crc,
+	}
+}
+
+// uploadFile uploads a local file, verified by its CRC-64, and returns its size.
+func uploadFile(ctx context.Context, client *railgunclient.Client, upload uploadFlags, filename, objectPath string) (int64, error) {
+	sums, err := railgunclient.ChecksumFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	_, err = client.PutFile(ctx, objectPath, filename, upload.options(objectPath, sums.CRC64))
+	return sums.Size, err
+}
@@ -1,6 +1,13 @@
 package config
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
@@ -11,12 +18,27 @@ var (
 	k    = koanf.New(".")
 	path = "config.yaml"
 	Conf Config
+
+	// state is the load state of the configuration file, checked by Check.
+	state struct {
+		sync.Mutex
+		loaded  bool
+		modTime time.Time
+		err     error
+	}
 )
 
 type Config struct {
 	ListenPort         string       `yaml:"ListenPort"`
 	MaxRequestBodySize int          `yaml:"MaxRequestBodySize"`
+	TLS                TLS          `yaml:"TLS"`
+	Admin              Admin        `yaml:"Admin"`
+	Logging            Logging      `yaml:"Logging"`
+	Shutdown           Shutdown     `yaml:"Shutdown"`
 	Matomo             MatomoClient `yaml:"Matomo"`
+	Analytics          Analytics    `yaml:"Analytics"`
+	AccessLog          AccessLog    `yaml:"AccessLog"`
+	Tracing            Tracing      `yaml:"Tracing"`
 	Services           Services     `yaml:"Services"`
 }
 
@@ -25,18 +47,51 @@ type Services struct {
 }
 
 type RailgunCDN struct {
-	COS     TencentCOS                                 `yaml:"COS"`
-	CDN     TencentCDN                                 `yaml:"CDN"`
-	Private PrivateCDN                                 `yaml:"Private"`
-	Tenants map[RailgunCDNTenantAppID]RailgunCDNTenant `yaml:"Tenants"`
+	COS        TencentCOS                                 `yaml:"COS"`
+	CDN        TencentCDN                                 `yaml:"CDN"`
+	Private    PrivateCDN                                 `yaml:"Private"`
+	Revocation RevocationStore                            `yaml:"Revocation"`
+	Tenants    map[RailgunCDNTenantAppID]RailgunCDNTenant `yaml:"Tenants"`
 }
 
 type RailgunCDNTenantAppID = string
 
 type RailgunCDNTenant struct {
-	AppKey   string `yaml:"AppKey"`
-	RootPath string `yaml:"RootPath"`
-	SiteID   string `yaml:"SiteID"`
+	AppKey     string                     `yaml:"AppKey"`
+	RootPath   string                     `yaml:"RootPath"` // Key prefix of the objects in the bucket, not empty nor under ".stargate/"
+	SiteID     string                     `yaml:"SiteID"`
+	AutoPurge  bool                       `yaml:"AutoPurge"`
+	Sinks      []string                   `yaml:"Sinks"`
+	Dimensions RailgunCDNTenantDimensions `yaml:"Dimensions"`
+	// Tracks requests as Matomo events of category "railgun_cdn" instead of pageviews, which changes the Matomo reports
+	// they appear in
+	TrackEvents bool             `yaml:"TrackEvents"`
+	Privacy     AnalyticsPrivacy `yaml:"Privacy"`
+	// Names of the client certificates authenticating as the tenant instead of its app key, matched against the
+	// subject common name, DNS names and URIs of the certificate
+	ClientCertNames []string `yaml:"ClientCertNames"`
+	// Public static website of the tenant, served without signed links
+	Website RailgunCDNTenantWebsite `yaml:"Website"`
+}
+
+// RailgunCDNTenantWebsite configures the public static website of a tenant.
+// Requests whose host matches one of its hosts are served entirely by the website, the API is not reachable on them.
+type RailgunCDNTenantWebsite struct {
+	Enabled       bool     `yaml:"Enabled"`
+	Hosts         []string `yaml:"Hosts"`         // Host names routed to the website, matched case-insensitively and without port
+	Prefix        string   `yaml:"Prefix"`        // Object path prefix of the website files, e.g. "/site", empty for the whole root path
+	IndexDocument string   `yaml:"IndexDocument"` // Served for directory paths, "index.html" if empty
+	ErrorDocument string   `yaml:"ErrorDocument"` // Object path under the prefix served with status 404 for missing files, e.g. "/404.html"
+	SPAFallback   bool     `yaml:"SPAFallback"`   // Serves the index document of the website root for missing paths without a file extension
+	CacheControl  string   `yaml:"CacheControl"`  // Cache-Control of files that have none, e.g. "public, max-age=300"
+	Redirect      bool     `yaml:"Redirect"`      // Redirects to short-lived signed CDN URLs instead of serving files through Stargate
+	RedirectTTLMs int      `yaml:"RedirectTTLMs"` // Lifetime of the signed CDN URLs of redirects, 300000 if 0
+}
+
+// RailgunCDNTenantDimensions maps request fields to Matomo custom dimension IDs, 0 disables a field.
+type RailgunCDNTenantDimensions struct {
+	Status    int `yaml:"Status"`
+	RequestID int `yaml:"RequestID"`
 }
 
 type TencentCOS struct {
@@ -47,21 +102,112 @@ type TencentCOS struct {
 }
 
 type TencentCDN struct {
-	Endpoint        string `yaml:"Endpoint"`
-	PKey            string `yaml:"PKey"`
-	TimestampOffset int64  `yaml:"TimestampOffset"`
+	Endpoint        string             `yaml:"Endpoint"`
+	PKey            string             `yaml:"PKey"`
+	TimestampOffset int64              `yaml:"TimestampOffset"`
+	MaxTTL          int64              `yaml:"MaxTTL"` // Maximum lifespan of signed URLs in seconds, 604800 (7 days) if 0, revoked object paths are kept as long
+	API             TencentCDNCacheAPI `yaml:"API"`
+}
+
+type TencentCDNCacheAPI struct {
+	Endpoint  string `yaml:"Endpoint"`
+	SecretID  string `yaml:"SecretID"`
+	SecretKey string `yaml:"SecretKey"`
+	Mock      bool   `yaml:"Mock"`
 }
 
 type PrivateCDN struct {
 	Endpoint string `yaml:"Endpoint"`
 }
 
+type RevocationStore struct {
+	StorePath string `yaml:"StorePath"`
+}
+
+type TLS struct {
+	CertFile         string `yaml:"CertFile"`         // Serve HTTPS with this certificate, empty serves plain HTTP
+	KeyFile          string `yaml:"KeyFile"`          // Private key of the certificate
+	ReloadIntervalMs int    `yaml:"ReloadIntervalMs"` // Interval at which the files are checked for changes, default 10000
+	ClientCAFile     string `yaml:"ClientCAFile"`     // Verify client certificates against these CAs, empty disables mutual TLS
+	ClientAuth       string `yaml:"ClientAuth"`       // "optional" (default) | "require"
+	RedirectPort     string `yaml:"RedirectPort"`     // Redirect plain HTTP on this port to HTTPS, empty disables it
+	DisableHTTP2     bool   `yaml:"DisableHTTP2"`     // Serve HTTP/1.1 only, HTTP/2 is negotiated with ALPN otherwise
+}
+
+type Admin struct {
+	ListenPort string `yaml:"ListenPort"` // Serve health, metrics and docs on this port only, empty serves them on ListenPort
+	ListenHost string `yaml:"ListenHost"` // Interface of the admin listener, default "127.0.0.1"
+}
+
+type Shutdown struct {
+	ReadinessDelayMs int `yaml:"ReadinessDelayMs"` // Time between failing readiness and draining, for load balancers to notice
+	DrainTimeoutMs   int `yaml:"DrainTimeoutMs"`   // Longest wait for in-flight requests, default 30000
+	HookTimeoutMs    int `yaml:"HookTimeoutMs"`    // Time given to flush analytics, Matomo and traces afterward, default 15000
+}
+
+type Logging struct {
+	Level  string `yaml:"Level"`  // "trace" | "debug" | "info" (default) | "notice" | "warn" | "error" | "fatal"
+	Format string `yaml:"Format"` // "text" (default) | "json"
+	Output string `yaml:"Output"` // "stderr" (default) | "stdout" | file path
+}
+
 type MatomoClient struct {
-	Endpoint        string `yaml:"Endpoint"`
-	AuthToken       string `yaml:"AuthToken"`
-	NumWorkers      int    `yaml:"NumWorkers"`
-	BatchSize       int    `yaml:"BatchSize"`
-	EventBufferSize int    `yaml:"EventBufferSize"`
+	Endpoint          string  `yaml:"Endpoint"`
+	AuthToken         string  `yaml:"AuthToken"`
+	NumWorkers        int     `yaml:"NumWorkers"`
+	BatchSize         int     `yaml:"BatchSize"`         // Maximum number of events in a batch, default 50
+	BatchMaxBytes     int     `yaml:"BatchMaxBytes"`     // Cap on the tracking requests size of a batch, 0 disables it
+	EventBufferSize   int     `yaml:"EventBufferSize"`   // Events queued before the workers pick them up, default 1000
+	FlushIntervalMs   int     `yaml:"FlushIntervalMs"`   // Interval at which incomplete batches are sent, default 1000
+	TimeoutMs         int     `yaml:"TimeoutMs"`         // Timeout of a batch request, default 5000
+	ShutdownTimeoutMs int     `yaml:"ShutdownTimeoutMs"` // Time given to pending batches on shutdown, default 10000
+	Backpressure      string  `yaml:"Backpressure"`      // "drop_newest" (default) | "drop_oldest" | "block" | "sample"
+	BlockTimeoutMs    int     `yaml:"BlockTimeoutMs"`    // block: longest delay of a request, default 100
+	SampleRate        float64 `yaml:"SampleRate"`        // sample: fraction of events kept once the buffer is half full, default 0.1
+	MaxRetries        int     `yaml:"MaxRetries"`
+	SpoolDir          string  `yaml:"SpoolDir"` // Dedicated Matomo sinks spool in a subdirectory named after the sink
+	SpoolMaxBytes     int64   `yaml:"SpoolMaxBytes"`
+}
+
+type AccessLog struct {
+	Path                string `yaml:"Path"`                // Empty disables the access log
+	MaxBytes            int64  `yaml:"MaxBytes"`            // Rotate once the file reaches this size, 0 disables it
+	RotateIntervalHours int    `yaml:"RotateIntervalHours"` // Rotate once the file is this old, 0 disables it
+	Compress            bool   `yaml:"Compress"`            // Compress rotated files with gzip
+}
+
+type Tracing struct {
+	Enabled     bool              `yaml:"Enabled"`
+	Endpoint    string            `yaml:"Endpoint"`    // OTLP/HTTP traces URL, defaults to the OTEL_EXPORTER_OTLP_* environment variables
+	Insecure    bool              `yaml:"Insecure"`    // Use plain HTTP
+	Headers     map[string]string `yaml:"Headers"`     // Sent with every export, e.g. for authentication
+	ServiceName string            `yaml:"ServiceName"` // Default "stargate"
+	SampleRatio float64           `yaml:"SampleRatio"` // Fraction of new traces sampled, default 1
+}
+
+type Analytics struct {
+	DefaultSinks []string                 `yaml:"DefaultSinks"`
+	Sinks        map[string]AnalyticsSink `yaml:"Sinks"`
+}
+
+type AnalyticsSink struct {
+	Type       string            `yaml:"Type"`       // "matomo" | "file" | "webhook" | "nats"
+	Path       string            `yaml:"Path"`       // file
+	URL        string            `yaml:"URL"`        // matomo (optional, uses its own client), webhook, nats (may hold user and password or token, "tls://" requires TLS)
+	AuthToken  string            `yaml:"AuthToken"`  // matomo, the client is configured like the Matomo section
+	Headers    map[string]string `yaml:"Headers"`    // webhook
+	BufferSize int               `yaml:"BufferSize"` // webhook, nats
+	Subject    string            `yaml:"Subject"`    // nats
+	CredsFile  string            `yaml:"CredsFile"`  // nats, user JWT and NKey seed file
+	CAFile     string            `yaml:"CAFile"`     // nats, CA certificates verifying the server, the system ones if empty
+}
+
+type AnalyticsPrivacy struct {
+	IPv4Prefix      int      `yaml:"IPv4Prefix"`      // Leading bits of client IPv4 addresses to keep, 0 keeps all
+	IPv6Prefix      int      `yaml:"IPv6Prefix"`      // Leading bits of client IPv6 addresses to keep, 0 keeps all
+	HonorDoNotTrack bool     `yaml:"HonorDoNotTrack"` // Skip events of clients sending "DNT: 1" or "Sec-GPC: 1"
+	StripUserAgent  bool     `yaml:"StripUserAgent"`  // Remove the user agent from events
+	OptOutPaths     []string `yaml:"OptOutPaths"`     // Object paths not to track, paths ending with "/" match directories
 }
 
 func Init() {
@@ -72,4 +218,38 @@ func Init() {
 	if err := k.Unmarshal("", &Conf); err != nil {
 		hlog.Fatalf("error unmarshalling config: %v", err)
 	}
+
+	state.Lock()
+	defer state.Unlock()
+	state.loaded = true
+	if info, err := os.Stat(path); err == nil {
+		state.modTime = info.ModTime()
+	}
+}
+
+// Check reports an error if the configuration is not loaded, or if the configuration file was modified since it was
+// loaded and no longer loads, so that a broken change is noticed before the next start. Changes are not applied.
+func Check(_ context.Context) error {
+	state.Lock()
+	defer state.Unlock()
+
+	if !state.loaded {
+		return errors.New("configuration is not loaded")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error checking config file: %w", err)
+	}
+	if info.ModTime().Equal(state.modTime) {
+		return state.err
+	}
+	state.modTime = info.ModTime()
+	state.err = nil
+	reloaded := koanf.New(".")
+	if err := reloaded.Load(file.Provider(path), yaml.Parser()); err != nil {
+		state.err = fmt.Errorf("error reloading modified config: %w", err)
+	} else if err := reloaded.Unmarshal("", &Config{}); err != nil {
+		state.err = fmt.Errorf("error unmarshalling modified config: %w", err)
+	}
+	return state.err
 }
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	path = filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("ListenPort: \"8080\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(context.Background()); err == nil {
+		t.Error("Check() = nil before the configuration is loaded")
+	}
+	Init()
+	if err := Check(context.Background()); err != nil {
+		t.Errorf("Check() = %v after loading", err)
+	}
+
+	modified := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte("ListenPort: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(context.Background()); err == nil {
+		t.Error("Check() = nil after the config file was broken")
+	}
+	if err := Check(context.Background()); err == nil {
+		t.Error("Check() = nil on the next check of the unchanged broken file")
+	}
+
+	modified = modified.Add(time.Minute)
+	if err := os.WriteFile(path, []byte("ListenPort: \"8081\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(context.Background()); err != nil {
+		t.Errorf("Check() = %v after the config file was fixed", err)
+	}
+	if Conf.ListenPort != "8080" {
+		t.Errorf("ListenPort = %q, the modified file must not be applied", Conf.ListenPort)
+	}
+}
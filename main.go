@@ -2,37 +2,139 @@ package main
 
 import (
 	"context"
+	"net"
+	"os"
 
 	"github.com/cloudwego/hertz/pkg/app/server"
-	"github.com/hertz-contrib/requestid"
+	hertzconfig "github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
 
 	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/accesslog"
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/app/common/logging"
 	"github.com/tundrawork/stargate/app/common/matomo"
+	"github.com/tundrawork/stargate/app/common/metrics"
+	"github.com/tundrawork/stargate/app/common/requestid"
+	"github.com/tundrawork/stargate/app/common/shutdown"
+	"github.com/tundrawork/stargate/app/common/tlsserver"
+	"github.com/tundrawork/stargate/app/common/tracing"
 	"github.com/tundrawork/stargate/app/railgun_cdn"
 	"github.com/tundrawork/stargate/config"
 	"github.com/tundrawork/stargate/router"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	config.Init()
-	h := server.Default(
-		server.WithHostPorts(":"+config.Conf.ListenPort),
+	logging.Init(config.Conf.Logging)
+	shutdown.Init(config.Conf.Shutdown)
+	opts := []hertzconfig.Option{
+		server.WithHostPorts(":" + config.Conf.ListenPort),
 		server.WithHandleMethodNotAllowed(true),
 		server.WithStreamBody(true),
 		server.WithMaxRequestBodySize(config.Conf.MaxRequestBodySize),
-	)
+		server.WithExitWaitTime(shutdown.HookTimeout()),
+	}
+	if config.Conf.TLS.CertFile != "" {
+		tlsConfig, err := tlsserver.NewConfig(config.Conf.TLS)
+		if err != nil {
+			hlog.Fatalf("[TLS] Error loading TLS configuration: %v", err)
+		}
+		opts = append(opts, tlsserver.ServerOptions(tlsConfig)...)
+	}
+	h := server.Default(opts...)
+	if config.Conf.TLS.CertFile != "" {
+		tlsserver.EnableHTTP2(h, config.Conf.TLS)
+	}
+	h.SetCustomSignalWaiter(shutdown.SignalWaiter)
 	h.Use(
 		requestid.New(),
+		tracing.Middleware(),
+		logging.Middleware(),
+		metrics.Middleware(),
+		shutdown.Middleware(router.UploadRoutes...),
+		railgun_cdn.WebsiteMiddleware(),
 	)
-	h.LoadHTMLGlob("docs/*")
 	initServices(h)
-	router.Register(h)
+	admin := newAdminServer()
+	router.RegisterServers(h, admin)
+	if admin != nil {
+		startAdminServer(h, admin)
+	} else {
+		h.LoadHTMLGlob("docs/*.tmpl")
+	}
+	if config.Conf.TLS.CertFile != "" && config.Conf.TLS.RedirectPort != "" {
+		startRedirectServer(h)
+	}
 	h.Spin()
 }
 
+// newAdminServer creates the server of the operational routes, or returns nil if they share the public listener.
+// It has no tracing nor metrics middleware on purpose: probes and scrapes every few seconds would flood the traces,
+// and skew the request metrics of the tenants they are served next to.
+func newAdminServer() *server.Hertz {
+	if config.Conf.Admin.ListenPort == "" {
+		return nil
+	}
+	host := config.Conf.Admin.ListenHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	admin := server.Default(
+		server.WithHostPorts(net.JoinHostPort(host, config.Conf.Admin.ListenPort)),
+		server.WithHandleMethodNotAllowed(true),
+	)
+	admin.Use(
+		requestid.New(),
+		logging.Middleware(),
+	)
+	return admin
+}
+
+// startAdminServer serves the operational routes on their own listener, until the main server shuts down.
+// It is not drained, so that the readiness check keeps reporting the drain to load balancers.
+func startAdminServer(h *server.Hertz, admin *server.Hertz) {
+	admin.LoadHTMLGlob("docs/*.tmpl")
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		if err := admin.Shutdown(ctx); err != nil {
+			hlog.CtxErrorf(ctx, "[Admin] Error shutting down admin server: %v", err)
+		}
+	})
+	go func() {
+		if err := admin.Run(); err != nil {
+			hlog.Errorf("[Admin] Admin server stopped: %v", err)
+		}
+	}()
+}
+
+// startRedirectServer serves the redirect from plain HTTP to HTTPS, until the main server shuts down.
+func startRedirectServer(h *server.Hertz) {
+	redirect := tlsserver.NewRedirectServer(config.Conf.TLS.RedirectPort, config.Conf.ListenPort)
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		if err := redirect.Shutdown(ctx); err != nil {
+			hlog.CtxErrorf(ctx, "[TLS] Error shutting down redirect server: %v", err)
+		}
+	})
+	go func() {
+		if err := redirect.Run(); err != nil {
+			hlog.Errorf("[TLS] Redirect server stopped: %v", err)
+		}
+	}()
+}
+
+// initServices initializes the services, and registers the hook flushing them once requests are drained.
 func initServices(server *server.Hertz) {
 	server.OnShutdown = append(server.OnShutdown, func(ctx context.Context) {
+		accesslog.Shutdown(ctx)
+		analytics.Shutdown(ctx)
 		matomo.Shutdown(ctx)
+		tracing.Shutdown(ctx)
 	})
 	common.Init()
 	railgun_cdn.Init()
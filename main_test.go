@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tundrawork/stargate/config"
+)
+
+// TestNewAdminServer checks that the operational routes get their own server only when an admin port is configured.
+func TestNewAdminServer(t *testing.T) {
+	saved := config.Conf.Admin
+	t.Cleanup(func() { config.Conf.Admin = saved })
+
+	config.Conf.Admin = config.Admin{}
+	if admin := newAdminServer(); admin != nil {
+		t.Error("admin server created without admin port")
+	}
+	config.Conf.Admin = config.Admin{ListenPort: "9090"}
+	if admin := newAdminServer(); admin == nil {
+		t.Error("no admin server created for the admin port")
+	}
+}
@@ -0,0 +1,255 @@
+// Package railgunclient is a Go client of the Railgun CDN API of Stargate.
+//
+// It handles the tenant authentication headers, decodes the API response envelope into typed results and retries
+// requests failing with retryable errors, with an exponential backoff.
+package railgunclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Client calls the Railgun CDN API as a tenant. It is safe for concurrent use.
+type Client struct {
+	endpoint   string
+	appID      string
+	appKey     string
+	httpClient *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, e.g. one presenting a client certificate.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets the number of retries of requests failing with a retryable error, 0 disables retries.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled for every retry up to maxBackoff.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client of the Stargate server at endpoint, e.g. "https://stargate.example.com".
+// The appKey may be empty if the HTTP client presents a client certificate of the tenant.
+func New(endpoint, appID, appKey string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		appID:      appID,
+		appKey:     appKey,
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// envelope is the response body of every API call.
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   *struct {
+		Code      string `json:"code"`
+		Retryable bool   `json:"retryable"`
+		RequestID string `json:"requestId"`
+	} `json:"error"`
+}
+
+// request is an API call, body is sent as is if it is an io.Reader and encoded as JSON otherwise.
+type request struct {
+	method  string
+	path    string
+	headers http.Header
+	body    any
+	// Whether the call can be repeated without changing its effect, which is the case for the idempotent HTTP methods
+	// and set for the POST and PATCH calls that are
+	idempotent bool
+}
+
+// retryable reports whether the call can be sent again after failing with err. Failures without an API error, e.g.
+// connection errors, may happen after the server handled the call, so only idempotent calls are retried on them.
+func (r request) retryable(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !apiErr.Retryable {
+		return false
+	}
+	if apiErr.api {
+		return true
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return r.idempotent
+}
+
+// do calls the API, retrying on retryable errors, and decodes the data of the response into result if not nil.
+func (c *Client) do(ctx context.Context, req request, result any) error {
+	var (
+		body     []byte
+		reader   io.Reader
+		rewinder io.Seeker
+		start    int64
+	)
+	switch b := req.body.(type) {
+	case nil:
+	case io.Reader:
+		// Streams can only be retried if they can be rewound to where the first attempt started reading
+		reader = b
+		if seeker, ok := b.(io.Seeker); ok {
+			if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+				rewinder, start = seeker, offset
+			}
+		}
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := c.backoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		err = c.doOnce(ctx, req, reader, result)
+		if err == nil || attempt >= c.maxRetries || !req.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if reader != nil && body == nil {
+			if rewinder == nil {
+				return err
+			}
+			if _, seekErr := rewinder.Seek(start, io.SeekStart); seekErr != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, req request, body io.Reader, result any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.endpoint+req.path, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range req.headers {
+		httpReq.Header[key] = values
+	}
+	if _, ok := req.body.(io.Reader); !ok && req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if sized, ok := body.(interface{ Size() int64 }); ok {
+		httpReq.ContentLength = sized.Size()
+		if seeker, ok := body.(io.Seeker); ok {
+			// Only the rest of the body is sent
+			if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+				httpReq.ContentLength -= offset
+			}
+		}
+	}
+	httpReq.Header.Set("X-App-Id", c.appID)
+	if c.appKey != "" {
+		httpReq.Header.Set("X-App-Key", c.appKey)
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return &Error{Code: CodeUnavailable, Message: err.Error(), Retryable: true, Err: err}
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close() // Ignore error
+	}(resp.Body)
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		// Not an API response, e.g. from a proxy in front of Stargate
+		return &Error{
+			Status:    resp.StatusCode,
+			Code:      CodeInternal,
+			Message:   fmt.Sprintf("unexpected response: %s", resp.Status),
+			Retryable: resp.StatusCode >= http.StatusInternalServerError,
+			Err:       err,
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{
+			Status:  resp.StatusCode,
+			Code:    CodeInternal,
+			Message: env.Message,
+		}
+		if env.Error != nil {
+			apiErr.Code = env.Error.Code
+			apiErr.Retryable = env.Error.Retryable
+			apiErr.RequestID = env.Error.RequestID
+			apiErr.api = true
+		}
+		return apiErr
+	}
+	if result == nil || len(env.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Data, result)
+}
+
+// backoff waits before a retry, with full jitter.
+func (c *Client) backoff(ctx context.Context, attempt int) error {
+	delay := c.minBackoff << (attempt - 1)
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+	if delay > 0 {
+		delay = rand.N(delay) + 1
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsRetryable reports whether the request failing with err may succeed if retried later.
+func IsRetryable(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Retryable
+}
@@ -0,0 +1,319 @@
+package railgunclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/network/standard"
+
+	"github.com/tundrawork/stargate/app/common/requestid"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api/costest"
+	"github.com/tundrawork/stargate/config"
+	"github.com/tundrawork/stargate/router"
+)
+
+// newTestClient starts Stargate with the tenant app-a, backed by an in-memory COS bucket and a mock CDN cache,
+// and returns a client of the tenant.
+func newTestClient(t *testing.T) (*Client, *costest.Bucket) {
+	t.Helper()
+	bucket := costest.NewBucket()
+	t.Cleanup(bucket.Close)
+	api.SetCosClient(bucket.URL(), bucket.Client())
+	api.SetCDNCache(&api.MockCDNCache{})
+	config.Conf.Services.RailgunCDN.CDN.Endpoint = "https://cdn.example.com"
+	config.Conf.Services.RailgunCDN.Tenants = map[config.RailgunCDNTenantAppID]config.RailgunCDNTenant{
+		"app-a": {AppKey: "key-a", RootPath: "app-a"},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	// The standard transport goes through the Go network poller, which orders the handlers of the server before the
+	// responses read by the client for the race detector, netpoll does not
+	h := server.New(
+		server.WithHostPorts(addr),
+		server.WithTransport(standard.NewTransporter),
+		server.WithHandleMethodNotAllowed(true),
+		server.WithStreamBody(true),
+	)
+	h.Use(requestid.New())
+	router.Register(h)
+	go func() { _ = h.Run() }()
+	t.Cleanup(func() { _ = h.Close() })
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Stargate is not listening on %s: %v", addr, err)
+		}
+	}
+	return New("http://"+addr, "app-a", "key-a", WithBackoff(time.Millisecond, time.Millisecond)), bucket
+}
+
+// countRequests counts the requests to the bucket with the given method, failing those for which fail returns a failure.
+func countRequests(bucket *costest.Bucket, method string, fail func(r *http.Request) *costest.Failure) *atomic.Int32 {
+	count := new(atomic.Int32)
+	bucket.Fail = func(r *http.Request, _ []byte) *costest.Failure {
+		if r.Method != method {
+			return nil
+		}
+		count.Add(1)
+		if fail == nil {
+			return nil
+		}
+		return fail(r)
+	}
+	return count
+}
+
+func TestEnvelopeDecoding(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	headers := ObjectHeaders{ContentType: "image/png", Meta: map[string]string{"owner": "ci"}}
+	if _, err := c.Put(ctx, "/a.png", strings.NewReader("png"), PutOptions{ObjectHeaders: headers}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	metadata, err := c.Head(ctx, "/a.png")
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+	crc := strconv.FormatUint(crc64.Checksum([]byte("png"), crc64.MakeTable(crc64.ECMA)), 10)
+	if metadata.ContentType != "image/png" || metadata.ContentLength != 3 || metadata.CRC64 != crc || metadata.Meta["owner"] != "ci" {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	c, _ := newTestClient(t)
+	c.maxRetries = 0
+	_, err := c.Head(context.Background(), "/missing.png")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error %v is not an *Error", err)
+	}
+	if apiErr.Status != http.StatusNotFound || apiErr.Code != CodeNotFound || apiErr.Retryable || apiErr.RequestID == "" {
+		t.Errorf("got %+v, want a non-retryable %s with the request ID", apiErr, CodeNotFound)
+	}
+
+	c.appKey = "wrong-key"
+	if _, err := c.Head(context.Background(), "/missing.png"); !IsCode(err, CodeUnauthorized) {
+		t.Errorf("Head with a wrong app key: %v, want %s", err, CodeUnauthorized)
+	}
+
+	// Responses of proxies in front of Stargate are not API responses
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	t.Cleanup(proxy.Close)
+	c = New(proxy.URL, "app-a", "key-a", WithBackoff(time.Millisecond, time.Millisecond))
+	c.maxRetries = 0
+	_, err = c.Head(context.Background(), "/a.png")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error %v is not an *Error", err)
+	}
+	if apiErr.Status != http.StatusBadGateway || apiErr.Code != CodeInternal || !apiErr.Retryable || apiErr.RequestID != "" {
+		t.Errorf("got %+v, want a retryable %s without request ID", apiErr, CodeInternal)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c, bucket := newTestClient(t)
+	var bodies []string
+	var mutex sync.Mutex
+	attempts := new(atomic.Int32)
+	bucket.Fail = func(r *http.Request, body []byte) *costest.Failure {
+		if r.Method != http.MethodPut || r.Header.Get("x-cos-copy-source") != "" {
+			return nil
+		}
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		mutex.Unlock()
+		switch attempts.Add(1) {
+		case 1:
+			return &costest.Failure{Status: http.StatusServiceUnavailable, Code: "ServiceUnavailable"}
+		case 2:
+			return &costest.Failure{Status: http.StatusServiceUnavailable, Code: "SlowDown"}
+		}
+		return nil
+	}
+
+	// Only the part of the stream after its position is uploaded
+	stream := strings.NewReader("skipped:hello")
+	_, _ = stream.Seek(int64(len("skipped:")), io.SeekStart)
+	result, err := c.Put(context.Background(), "/a.txt", stream, PutOptions{})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if result.ETag == "" {
+		t.Error("Put returned no ETag")
+	}
+	if !slices.Equal(bodies, []string{"hello", "hello", "hello"}) {
+		t.Errorf("the seekable body was not sent again from its position on retries: %q", bodies)
+	}
+	if data, _ := bucket.Object("app-a/a.txt"); string(data) != "hello" {
+		t.Errorf("stored %q, want the uploaded body", data)
+	}
+
+	attempts.Store(0)
+	c.maxRetries = 1
+	if _, err := c.Put(context.Background(), "/a.txt", strings.NewReader("hello"), PutOptions{}); !IsCode(err, CodeRateLimited) {
+		t.Errorf("Put after exhausting retries: %v, want %s", err, CodeRateLimited)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	c, bucket := newTestClient(t)
+	puts := countRequests(bucket, http.MethodPut, func(*http.Request) *costest.Failure {
+		return &costest.Failure{Status: http.StatusServiceUnavailable, Code: "ServiceUnavailable"}
+	})
+
+	// A stream that cannot be rewound is sent only once
+	stream := struct{ io.Reader }{strings.NewReader("hello")}
+	if _, err := c.Put(context.Background(), "/a.txt", stream, PutOptions{}); !IsRetryable(err) {
+		t.Errorf("Put: %v, want a retryable error", err)
+	}
+	if n := puts.Load(); n != 1 {
+		t.Errorf("non-seekable Put attempted %d times, want 1", n)
+	}
+
+	heads := countRequests(bucket, http.MethodHead, func(*http.Request) *costest.Failure {
+		return &costest.Failure{Status: http.StatusBadRequest, Code: "InvalidArgument"}
+	})
+	if _, err := c.Head(context.Background(), "/a.txt"); !IsCode(err, CodeInvalidRequest) {
+		t.Errorf("Head: %v, want %s", err, CodeInvalidRequest)
+	}
+	if n := heads.Load(); n != 1 {
+		t.Errorf("non-retryable error attempted %d times, want 1", n)
+	}
+}
+
+func TestConnectionErrorRetry(t *testing.T) {
+	var attempts atomic.Int32
+	// The connection is lost before a response, the server may have handled the request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+	c := New(server.URL, "app-a", "key-a", WithBackoff(time.Millisecond, time.Millisecond))
+
+	if _, err := c.Head(context.Background(), "/a.txt"); !IsCode(err, CodeUnavailable) {
+		t.Errorf("Head: %v, want %s", err, CodeUnavailable)
+	}
+	if n := attempts.Load(); n != int32(c.maxRetries+1) {
+		t.Errorf("idempotent call attempted %d times, want %d", n, c.maxRetries+1)
+	}
+
+	attempts.Store(0)
+	data := bytes.Repeat([]byte{1}, minPartSize)
+	if _, err := c.PutMultipart(context.Background(), "/big.bin", bytes.NewReader(data), int64(len(data)), MultipartOptions{PartSize: minPartSize}); !IsRetryable(err) {
+		t.Errorf("PutMultipart: %v, want a retryable error", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("upload initiation attempted %d times, want 1", n)
+	}
+}
+
+func TestPutMultipart(t *testing.T) {
+	c, bucket := newTestClient(t)
+	parts := countRequests(bucket, http.MethodPut, nil)
+	data := bytes.Repeat([]byte("0123456789"), minPartSize/4) // 2.5 parts of 1 MiB
+
+	result, err := c.PutMultipart(context.Background(), "/big.bin", bytes.NewReader(data), int64(len(data)), MultipartOptions{PartSize: minPartSize})
+	if err != nil {
+		t.Fatalf("PutMultipart: %v", err)
+	}
+	if !strings.HasSuffix(strings.Trim(result.ETag, `"`), "-3") {
+		t.Errorf("ETag = %q, want the ETag of an object of 3 parts", result.ETag)
+	}
+	if n := parts.Load(); n != 3 {
+		t.Errorf("uploaded %d parts, want 3", n)
+	}
+	if object, _ := bucket.Object("app-a/big.bin"); !bytes.Equal(object, data) {
+		t.Errorf("assembled object of %d bytes differs from the %d bytes uploaded", len(object), len(data))
+	}
+	if n := bucket.Uploads(); n != 0 {
+		t.Errorf("%d uploads left open", n)
+	}
+}
+
+func TestPutMultipartAbortsOnFailure(t *testing.T) {
+	c, bucket := newTestClient(t)
+	countRequests(bucket, http.MethodPut, func(r *http.Request) *costest.Failure {
+		if r.URL.Query().Get("partNumber") == "2" {
+			return &costest.Failure{Status: http.StatusBadRequest, Code: "InvalidArgument"}
+		}
+		return nil
+	})
+	data := bytes.Repeat([]byte{1}, 3*minPartSize)
+
+	_, err := c.PutMultipart(context.Background(), "/big.bin", bytes.NewReader(data), int64(len(data)), MultipartOptions{PartSize: minPartSize})
+	if !IsCode(err, CodeInvalidRequest) {
+		t.Fatalf("PutMultipart: %v, want the error of the failed part", err)
+	}
+	if n := bucket.Uploads(); n != 0 {
+		t.Error("failed upload was not aborted")
+	}
+	if _, ok := bucket.Object("app-a/big.bin"); ok {
+		t.Error("failed upload was completed")
+	}
+}
+
+func TestPlanSyncListsOnlyPrefix(t *testing.T) {
+	c, bucket := newTestClient(t)
+	ctx := context.Background()
+	for objectPath, data := range map[string]string{"/site/a.txt": "a", "/site/old.txt": "old", "/sites/b.txt": "b", "/other/c.txt": "c"} {
+		if _, err := c.Put(ctx, objectPath, strings.NewReader(data), PutOptions{}); err != nil {
+			t.Fatalf("Put(%q): %v", objectPath, err)
+		}
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	bucket.Fail = func(r *http.Request, _ []byte) *costest.Failure {
+		if r.Method == http.MethodGet && r.URL.Path == "/" {
+			listed = append(listed, r.URL.Query().Get("prefix"))
+		}
+		return nil
+	}
+
+	plan, err := c.PlanSync(ctx, dir, "/site", SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("PlanSync: %v", err)
+	}
+	if !slices.Equal(listed, []string{"app-a/site/"}) {
+		t.Errorf("listed the prefixes %q, want only the one of the synced directory", listed)
+	}
+	if plan.Unchanged != 1 || len(plan.Actions) != 1 || !plan.Actions[0].Delete || plan.Actions[0].ObjectPath != "/site/old.txt" {
+		t.Errorf("plan = %+v, want to delete /site/old.txt only", plan)
+	}
+}
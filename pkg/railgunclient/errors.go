@@ -0,0 +1,58 @@
+package railgunclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes of the API, see the errors section of the API documentation.
+const (
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodeChecksumMismatch   = "CHECKSUM_MISMATCH"
+	CodeTooLarge           = "TOO_LARGE"
+	CodeRevoked            = "REVOKED"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeUnavailable        = "UNAVAILABLE"
+	CodeTimeout            = "TIMEOUT"
+	CodeBackendError       = "BACKEND_ERROR"
+	CodeBackendDenied      = "BACKEND_DENIED"
+	CodeInternal           = "INTERNAL"
+)
+
+// Error is an error returned by the API, or a failure to reach it.
+type Error struct {
+	Status    int    // HTTP status, 0 if the server could not be reached
+	Code      string // One of the Code constants
+	Message   string
+	Retryable bool
+	RequestID string // To be given when reporting the error, empty if the server could not be reached
+	Err       error  // The underlying error if the server could not be reached or the response not decoded
+
+	api bool // Returned by the API, whose retryable flag tells whether the call was left without effect
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s: %s (request %s)", e.Code, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsCode reports whether err is an Error with the given code.
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// IsNotFound reports whether err is a NOT_FOUND error.
+func IsNotFound(err error) bool {
+	return IsCode(err, CodeNotFound)
+}
@@ -0,0 +1,67 @@
+package railgunclient
+
+import (
+	"crypto/md5"
+	"errors"
+	"hash/crc64"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileChecksums are the checksums of a local file, as computed by the storage.
+type FileChecksums struct {
+	Size  int64
+	MD5   []byte // Raw MD5 digest, the ETag of objects uploaded in a single request
+	CRC64 uint64 // CRC-64/ECMA
+}
+
+// ChecksumFile computes the checksums of a local file in a single pass.
+func ChecksumFile(filename string) (FileChecksums, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return FileChecksums{}, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close() // Ignore error
+	}(file)
+	md5Hash := md5.New()
+	crc64Hash := crc64.New(crc64.MakeTable(crc64.ECMA))
+	size, err := io.Copy(io.MultiWriter(md5Hash, crc64Hash), file)
+	if err != nil {
+		return FileChecksums{}, err
+	}
+	return FileChecksums{
+		Size:  size,
+		MD5:   md5Hash.Sum(nil),
+		CRC64: crc64Hash.Sum64(),
+	}, nil
+}
+
+// LocalFiles returns the regular files under dir by their slash separated path relative to dir, starting with "/".
+func LocalFiles(dir string) (map[string]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+	files := make(map[string]string)
+	err = filepath.WalkDir(dir, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		files["/"+filepath.ToSlash(relPath)] = filename
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,175 @@
+package railgunclient
+
+import (
+	"context"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultPartSize        = 16 << 20
+	minPartSize            = 1 << 20 // Smallest part accepted by the storage, except for the last part
+	maxParts               = 10000
+	defaultPartConcurrency = 4
+)
+
+// MultipartOptions are the optional parameters of a multipart upload.
+type MultipartOptions struct {
+	ObjectHeaders
+	TTL         int64  // Lifespan of the object in seconds, 0 keeps it forever
+	CRC64       uint64 // Expected CRC-64/ECMA of the whole object, verified by the server. 0 is not checked.
+	PartSize    int64  // Size of the parts, default 16 MiB, at least 1 MiB, increased if the object needs over 10000 parts
+	Concurrency int    // Number of parts uploaded at once, default 4
+}
+
+// uploadedPart is a part of a multipart upload, as listed to complete the upload.
+type uploadedPart struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// PutMultipart uploads an object of size bytes read from body in parts uploaded concurrently, for objects too large
+// to be uploaded by Put in a single request. Each part is verified with its CRC-64 and retried on its own, and the
+// upload is aborted if a part fails. The ETag of an object uploaded in parts is not its MD5.
+func (c *Client) PutMultipart(ctx context.Context, objectPath string, body io.ReaderAt, size int64, opts MultipartOptions) (PutResult, error) {
+	if size <= 0 {
+		// Parts cannot be empty
+		return c.Put(ctx, objectPath, io.NewSectionReader(body, 0, 0), PutOptions{
+			ObjectHeaders: opts.ObjectHeaders,
+			TTL:           opts.TTL,
+			CRC64:         opts.CRC64,
+		})
+	}
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	partSize = max(partSize, minPartSize, (size+maxParts-1)/maxParts)
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPartConcurrency
+	}
+
+	headers := objectHeader(objectPath)
+	opts.ObjectHeaders.set(headers)
+	if opts.TTL > 0 {
+		headers.Set("X-TTL", strconv.FormatInt(opts.TTL, 10))
+	}
+	var upload struct {
+		UploadID string `json:"uploadId"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/railgun/v1/object/uploads", headers: headers}, &upload); err != nil {
+		return PutResult{}, err
+	}
+	parts, err := c.uploadParts(ctx, objectPath, upload.UploadID, body, size, partSize, concurrency)
+	if err != nil {
+		c.abortUpload(ctx, objectPath, upload.UploadID)
+		return PutResult{}, err
+	}
+	headers = uploadHeader(objectPath, upload.UploadID)
+	if opts.CRC64 != 0 {
+		headers.Set("X-Checksum-CRC64", strconv.FormatUint(opts.CRC64, 10))
+	}
+	completeRequest := struct {
+		Parts []uploadedPart `json:"parts"`
+	}{parts}
+	var result PutResult
+	err = c.do(ctx, request{method: http.MethodPost, path: "/railgun/v1/object/uploads/complete", headers: headers, body: completeRequest}, &result)
+	if err != nil {
+		c.abortUpload(ctx, objectPath, upload.UploadID)
+		return PutResult{}, err
+	}
+	return result, nil
+}
+
+// PutFileMultipart uploads a local file in parts, see PutMultipart.
+func (c *Client) PutFileMultipart(ctx context.Context, objectPath, filename string, opts MultipartOptions) (PutResult, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return PutResult{}, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close() // Ignore error
+	}(file)
+	info, err := file.Stat()
+	if err != nil {
+		return PutResult{}, err
+	}
+	return c.PutMultipart(ctx, objectPath, file, info.Size(), opts)
+}
+
+// uploadParts uploads the parts of body concurrently, stopping at the first failed part.
+func (c *Client) uploadParts(ctx context.Context, objectPath, uploadID string, body io.ReaderAt, size, partSize int64, concurrency int) ([]uploadedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	parts := make([]uploadedPart, (size+partSize-1)/partSize)
+	var (
+		wg        sync.WaitGroup
+		errOnce   sync.Once
+		firstErr  error
+		semaphore = make(chan struct{}, concurrency)
+	)
+	for i := range parts {
+		semaphore <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			offset := int64(i) * partSize
+			part, err := c.uploadPart(ctx, objectPath, uploadID, i+1, io.NewSectionReader(body, offset, min(partSize, size-offset)))
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			parts[i] = part
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// uploadPart uploads a part with its CRC-64, retrying on retryable errors as the section can be rewound.
+func (c *Client) uploadPart(ctx context.Context, objectPath, uploadID string, partNumber int, part *io.SectionReader) (uploadedPart, error) {
+	crc64Hash := crc64.New(crc64.MakeTable(crc64.ECMA))
+	if _, err := io.Copy(crc64Hash, part); err != nil {
+		return uploadedPart{}, err
+	}
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return uploadedPart{}, err
+	}
+	headers := uploadHeader(objectPath, uploadID)
+	headers.Set("X-Part-Number", strconv.Itoa(partNumber))
+	headers.Set("X-Checksum-CRC64", strconv.FormatUint(crc64Hash.Sum64(), 10))
+	headers.Set("Content-Type", "application/octet-stream")
+	var result uploadedPart
+	err := c.do(ctx, request{method: http.MethodPut, path: "/railgun/v1/object/uploads/part", headers: headers, body: part}, &result)
+	return result, err
+}
+
+// abortUpload aborts a failed multipart upload to free its parts, even if ctx is canceled.
+// A failure to abort is not reported, as the upload already failed.
+func (c *Client) abortUpload(ctx context.Context, objectPath, uploadID string) {
+	_ = c.do(context.WithoutCancel(ctx), request{method: http.MethodDelete, path: "/railgun/v1/object/uploads", headers: uploadHeader(objectPath, uploadID)}, nil)
+}
+
+func uploadHeader(objectPath, uploadID string) http.Header {
+	headers := objectHeader(objectPath)
+	headers.Set("X-Upload-Id", uploadID)
+	return headers
+}
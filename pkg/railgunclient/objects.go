@@ -0,0 +1,270 @@
+package railgunclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ObjectMetadata is the metadata of an object. Listings without metadata only fill the content length, ETag,
+// last modified time and CRC-64.
+type ObjectMetadata struct {
+	ContentType        string            `json:"content-type"`
+	ContentLength      int64             `json:"content-length"`
+	ETag               string            `json:"etag"`
+	LastModified       string            `json:"last-modified"`
+	CRC64              string            `json:"crc64"` // CRC-64/ECMA, in decimal
+	CacheControl       string            `json:"cache-control,omitempty"`
+	ContentDisposition string            `json:"content-disposition,omitempty"`
+	ContentEncoding    string            `json:"content-encoding,omitempty"`
+	ContentLanguage    string            `json:"content-language,omitempty"`
+	Meta               map[string]string `json:"meta,omitempty"`
+}
+
+// ObjectHeaders are the headers and user metadata stored with an object, empty fields are not sent.
+type ObjectHeaders struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	Meta               map[string]string
+}
+
+// PutOptions are the optional parameters of an upload.
+type PutOptions struct {
+	ObjectHeaders
+	TTL int64 // Lifespan of the object in seconds, 0 keeps it forever
+
+	// Expected checksums of the body, verified by the server. Empty checksums are not checked.
+	MD5    []byte
+	SHA256 []byte
+	CRC64  uint64
+}
+
+// PutResult is the result of an upload or metadata update.
+type PutResult struct {
+	ETag  string `json:"etag"`
+	CRC64 string `json:"crc64"`
+}
+
+// SignedURL is a signed URL of an object.
+type SignedURL struct {
+	URL     string `json:"url"`
+	Expires int64  `json:"expires"` // Unix time in seconds
+}
+
+// SignRequest is an object to sign in a batch, a zero TTL uses the default TTL of the batch.
+type SignRequest struct {
+	ObjectPath string `json:"objectPath"`
+	TTL        int64  `json:"ttl"`
+}
+
+// SignResult is the signed URL of an object in a batch, or the error signing it.
+type SignResult struct {
+	URL     string `json:"url,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Revocation selects the signed URLs to revoke, at least one field must be set.
+type Revocation struct {
+	URL        string `json:"url,omitempty"`        // A single signed URL
+	ObjectPath string `json:"objectPath,omitempty"` // All URLs of an object issued so far
+	Before     int64  `json:"before,omitempty"`     // All URLs of the tenant issued before this Unix time
+}
+
+// List lists the objects of the tenant whose path starts with prefix by object path, all of them if prefix is empty.
+// With withMetadata, the full metadata of every object is returned, which is slower for large buckets.
+func (c *Client) List(ctx context.Context, prefix string, withMetadata bool) (map[string]ObjectMetadata, error) {
+	headers := http.Header{}
+	if prefix != "" {
+		headers.Set("X-Prefix", prefix)
+	}
+	if withMetadata {
+		headers.Set("X-With-Metadata", "true")
+	}
+	var objects map[string]ObjectMetadata
+	err := c.do(ctx, request{method: http.MethodGet, path: "/railgun/v1/bucket", headers: headers}, &objects)
+	return objects, err
+}
+
+// Head returns the metadata of an object.
+func (c *Client) Head(ctx context.Context, objectPath string) (ObjectMetadata, error) {
+	var metadata ObjectMetadata
+	err := c.do(ctx, request{method: http.MethodGet, path: "/railgun/v1/object", headers: objectHeader(objectPath)}, &metadata)
+	return metadata, err
+}
+
+// Put uploads an object, streaming body. The upload is retried only if body is an io.Seeker.
+func (c *Client) Put(ctx context.Context, objectPath string, body io.Reader, opts PutOptions) (PutResult, error) {
+	headers := objectHeader(objectPath)
+	opts.ObjectHeaders.set(headers)
+	if opts.TTL > 0 {
+		headers.Set("X-TTL", strconv.FormatInt(opts.TTL, 10))
+	}
+	if len(opts.MD5) > 0 {
+		headers.Set("Content-MD5", base64.StdEncoding.EncodeToString(opts.MD5))
+	}
+	if len(opts.SHA256) > 0 {
+		headers.Set("X-Checksum-SHA256", hex.EncodeToString(opts.SHA256))
+	}
+	if opts.CRC64 != 0 {
+		headers.Set("X-Checksum-CRC64", strconv.FormatUint(opts.CRC64, 10))
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/octet-stream")
+	}
+	var result PutResult
+	err := c.do(ctx, request{method: http.MethodPut, path: "/railgun/v1/object", headers: headers, body: body}, &result)
+	return result, err
+}
+
+// PutFile uploads a local file, retrying on retryable errors.
+func (c *Client) PutFile(ctx context.Context, objectPath, filename string, opts PutOptions) (PutResult, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return PutResult{}, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close() // Ignore error
+	}(file)
+	info, err := file.Stat()
+	if err != nil {
+		return PutResult{}, err
+	}
+	// A section reader has a known size for the Content-Length, and can be rewound for retries
+	return c.Put(ctx, objectPath, io.NewSectionReader(file, 0, info.Size()), opts)
+}
+
+// Update updates the headers and user metadata of an object without re-uploading it.
+// Empty headers keep their current values, user metadata is merged into the existing one.
+func (c *Client) Update(ctx context.Context, objectPath string, objectHeaders ObjectHeaders) (PutResult, error) {
+	headers := objectHeader(objectPath)
+	objectHeaders.set(headers)
+	var result PutResult
+	err := c.do(ctx, request{method: http.MethodPatch, path: "/railgun/v1/object", headers: headers, idempotent: true}, &result)
+	return result, err
+}
+
+// Delete deletes an object.
+func (c *Client) Delete(ctx context.Context, objectPath string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/railgun/v1/object", headers: objectHeader(objectPath)}, nil)
+}
+
+// Sign returns a signed URL of an object, valid for ttl.
+// The existence of the object is not checked.
+func (c *Client) Sign(ctx context.Context, objectPath string, ttl time.Duration) (SignedURL, error) {
+	headers := objectHeader(objectPath)
+	headers.Set("X-TTL", strconv.FormatInt(int64(ttl/time.Second), 10))
+	var signed SignedURL
+	err := c.do(ctx, request{method: http.MethodGet, path: "/railgun/v1/url", headers: headers}, &signed)
+	return signed, err
+}
+
+// downloadTTL is the lifespan of the signed URLs used to download objects.
+const downloadTTL = 5 * time.Minute
+
+// Get downloads an object through a short-lived signed URL, following the redirect of the gateway to the CDN.
+// The caller must close the returned body.
+func (c *Client) Get(ctx context.Context, objectPath string) (io.ReadCloser, error) {
+	signed, err := c.Sign(ctx, objectPath, downloadTTL)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, signed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, &Error{Code: CodeUnavailable, Message: err.Error(), Retryable: true, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close() // Ignore error
+		code := CodeBackendError
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			code = CodeNotFound
+		case http.StatusGone:
+			code = CodeRevoked
+		}
+		return nil, &Error{
+			Status:    resp.StatusCode,
+			Code:      code,
+			Message:   "download failed: " + resp.Status,
+			Retryable: resp.StatusCode >= http.StatusInternalServerError,
+		}
+	}
+	return resp.Body, nil
+}
+
+// SignBatch returns the signed URLs of up to 1000 objects by object path, with defaultTTL for objects without a TTL.
+// Objects failing to be signed have their error set instead of failing the whole batch.
+func (c *Client) SignBatch(ctx context.Context, objects []SignRequest, defaultTTL time.Duration) (map[string]SignResult, error) {
+	headers := http.Header{}
+	if defaultTTL > 0 {
+		headers.Set("X-TTL", strconv.FormatInt(int64(defaultTTL/time.Second), 10))
+	}
+	body := struct {
+		Objects []SignRequest `json:"objects"`
+	}{objects}
+	var results map[string]SignResult
+	err := c.do(ctx, request{method: http.MethodPost, path: "/railgun/v1/urls", headers: headers, body: body, idempotent: true}, &results)
+	return results, err
+}
+
+// Purge purges the CDN cache of objects, and of all objects under directories, returning the CDN task IDs.
+func (c *Client) Purge(ctx context.Context, objectPaths, directories []string) ([]string, error) {
+	return c.cache(ctx, "/railgun/v1/cache/purge", objectPaths, directories)
+}
+
+// Prefetch prefetches objects to the CDN edge nodes, returning the CDN task IDs.
+func (c *Client) Prefetch(ctx context.Context, objectPaths []string) ([]string, error) {
+	return c.cache(ctx, "/railgun/v1/cache/prefetch", objectPaths, nil)
+}
+
+func (c *Client) cache(ctx context.Context, path string, objectPaths, directories []string) ([]string, error) {
+	body := struct {
+		ObjectPaths []string `json:"objectPaths"`
+		Directories []string `json:"directories"`
+	}{objectPaths, directories}
+	var result struct {
+		TaskIDs []string `json:"taskIds"`
+	}
+	err := c.do(ctx, request{method: http.MethodPost, path: path, body: body}, &result)
+	return result.TaskIDs, err
+}
+
+// Revoke revokes signed URLs before they expire.
+func (c *Client) Revoke(ctx context.Context, revocation Revocation) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/railgun/v1/revoke", body: revocation, idempotent: true}, nil)
+}
+
+func objectHeader(objectPath string) http.Header {
+	headers := http.Header{}
+	headers.Set("X-Object-Path", objectPath)
+	return headers
+}
+
+func (h ObjectHeaders) set(headers http.Header) {
+	for key, value := range map[string]string{
+		"Content-Type":        h.ContentType,
+		"Cache-Control":       h.CacheControl,
+		"Content-Disposition": h.ContentDisposition,
+		"Content-Encoding":    h.ContentEncoding,
+		"Content-Language":    h.ContentLanguage,
+	} {
+		if value != "" {
+			headers.Set(key, value)
+		}
+	}
+	for key, value := range h.Meta {
+		headers.Set("X-Meta-"+key, value)
+	}
+}
@@ -0,0 +1,199 @@
+package railgunclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SyncCompare selects how local files are compared with objects of the same size.
+type SyncCompare string
+
+const (
+	// CompareETag compares the MD5 of files with the ETag of objects, objects uploaded in multiple parts are
+	// uploaded again as their ETag is not an MD5. This is the default.
+	CompareETag SyncCompare = "etag"
+	// CompareCRC64 compares the CRC-64 of files with the one of objects, which needs the full metadata of objects.
+	CompareCRC64 SyncCompare = "crc64"
+	// CompareSize only compares sizes.
+	CompareSize SyncCompare = "size"
+)
+
+// Reasons of sync actions.
+const (
+	ReasonMissing    = "missing"    // The object does not exist
+	ReasonSize       = "size"       // The object has another size
+	ReasonChecksum   = "checksum"   // The object has the same size but another ETag or CRC-64
+	ReasonExtraneous = "extraneous" // The object has no local file, and SyncOptions.Delete is set
+)
+
+// SyncOptions are the options of a sync.
+type SyncOptions struct {
+	Compare     SyncCompare
+	Delete      bool // Delete objects under the prefix without a local file
+	DryRun      bool // Only plan the actions in Sync
+	Concurrency int  // Number of concurrent actions, default 4
+	// Options returns the upload options of an object, e.g. its content type. The CRC-64 is set by the sync.
+	Options func(objectPath string) PutOptions
+	// Done is called after every action, concurrently, e.g. to report progress.
+	Done func(action SyncAction)
+}
+
+// SyncAction is an upload or a deletion planned by a sync.
+type SyncAction struct {
+	Delete     bool
+	ObjectPath string
+	Filename   string // Local file of uploads
+	Size       int64  // Size of the local file of uploads
+	Reason     string
+	Err        error // Set once done if the action failed
+}
+
+// SyncPlan is the list of actions making the objects under a prefix match a local directory.
+type SyncPlan struct {
+	Actions   []SyncAction // Sorted by object path, uploads first
+	Unchanged int          // Number of files already up to date
+
+	checksums map[string]FileChecksums // Of the files to upload, by object path
+}
+
+// Sync makes the objects under prefix match the files of the local directory dir: files missing or changed remotely
+// are uploaded, and with opts.Delete, objects without a local file are deleted. With opts.DryRun, it only plans.
+// The returned error joins the errors of the failed actions, the plan lists all actions anyway.
+func (c *Client) Sync(ctx context.Context, dir, prefix string, opts SyncOptions) (*SyncPlan, error) {
+	plan, err := c.PlanSync(ctx, dir, prefix, opts)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+	return plan, c.ApplySync(ctx, plan, opts)
+}
+
+// ApplySync runs the actions of a plan, setting the error of failed actions.
+// The returned error joins the errors of the failed actions.
+func (c *Client) ApplySync(ctx context.Context, plan *SyncPlan, opts SyncOptions) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		errs      []error
+		semaphore = make(chan struct{}, concurrency)
+	)
+	for i := range plan.Actions {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(action *SyncAction) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if action.Delete {
+				action.Err = c.Delete(ctx, action.ObjectPath)
+			} else {
+				var putOpts PutOptions
+				if opts.Options != nil {
+					putOpts = opts.Options(action.ObjectPath)
+				}
+				putOpts.CRC64 = plan.checksums[action.ObjectPath].CRC64
+				_, action.Err = c.PutFile(ctx, action.ObjectPath, action.Filename, putOpts)
+			}
+			if action.Err != nil {
+				mutex.Lock()
+				errs = append(errs, action.Err)
+				mutex.Unlock()
+			}
+			if opts.Done != nil {
+				opts.Done(*action)
+			}
+		}(&plan.Actions[i])
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// PlanSync compares the files of the local directory dir with the objects under prefix, and returns the actions
+// making them match.
+func (c *Client) PlanSync(ctx context.Context, dir, prefix string, opts SyncOptions) (*SyncPlan, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	compare := opts.Compare
+	if compare == "" {
+		compare = CompareETag
+	}
+	files, err := LocalFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := c.List(ctx, prefix+"/", compare == CompareCRC64)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{checksums: make(map[string]FileChecksums)}
+	for relPath, filename := range files {
+		objectPath := prefix + relPath
+		sums, err := ChecksumFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		reason := ""
+		object, ok := objects[objectPath]
+		switch {
+		case !ok:
+			reason = ReasonMissing
+		case object.ContentLength != sums.Size:
+			reason = ReasonSize
+		case !sameContent(object, sums, compare):
+			reason = ReasonChecksum
+		}
+		if reason == "" {
+			plan.Unchanged++
+			continue
+		}
+		plan.checksums[objectPath] = sums
+		plan.Actions = append(plan.Actions, SyncAction{
+			ObjectPath: objectPath,
+			Filename:   filename,
+			Size:       sums.Size,
+			Reason:     reason,
+		})
+	}
+	if opts.Delete {
+		for objectPath := range objects {
+			relPath, ok := strings.CutPrefix(objectPath, prefix+"/")
+			if !ok {
+				continue
+			}
+			if _, ok := files["/"+relPath]; !ok {
+				plan.Actions = append(plan.Actions, SyncAction{
+					Delete:     true,
+					ObjectPath: objectPath,
+					Reason:     ReasonExtraneous,
+				})
+			}
+		}
+	}
+	sort.Slice(plan.Actions, func(i, j int) bool {
+		a, b := plan.Actions[i], plan.Actions[j]
+		if a.Delete != b.Delete {
+			return !a.Delete
+		}
+		return a.ObjectPath < b.ObjectPath
+	})
+	return plan, nil
+}
+
+// sameContent reports whether an object of the same size as a local file has the same content.
+func sameContent(object ObjectMetadata, sums FileChecksums, compare SyncCompare) bool {
+	switch compare {
+	case CompareCRC64:
+		return object.CRC64 == strconv.FormatUint(sums.CRC64, 10)
+	case CompareSize:
+		return true
+	default:
+		return strings.EqualFold(strings.Trim(object.ETag, `"`), hex.EncodeToString(sums.MD5))
+	}
+}
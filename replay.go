@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tundrawork/stargate/app/common/accesslog"
+	"github.com/tundrawork/stargate/app/common/analytics"
+	"github.com/tundrawork/stargate/app/common/matomo"
+	"github.com/tundrawork/stargate/config"
+)
+
+// replayAccessLog implements the replay-accesslog command, which sends access log files to the Matomo bulk tracking
+// API, e.g. to backfill the requests served while Matomo was unavailable. It returns the exit code of the process.
+func replayAccessLog(args []string) int {
+	flags := flag.NewFlagSet("replay-accesslog", flag.ContinueOnError)
+	appID := flags.String("app", "", "only replay the records of this tenant")
+	batchSize := flags.Int("batch-size", 100, "number of events per bulk tracking request")
+	dryRun := flags.Bool("dry-run", false, "count the events that would be sent without sending them")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: stargate replay-accesslog [flags] FILE...\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || *batchSize <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	config.Init()
+	client := matomo.NewClient(config.Conf.Matomo.Endpoint, config.Conf.Matomo.AuthToken,
+		matomo.WithTimeout(30*time.Second),
+		matomo.WithRetries(config.Conf.Matomo.MaxRetries),
+	)
+	defer func() {
+		_ = client.Close(context.Background())
+	}()
+
+	ctx := context.Background()
+	var batch []matomo.Event
+	var sent, skipped int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !*dryRun {
+			if err := client.Send(ctx, batch); err != nil {
+				return err
+			}
+		}
+		sent += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, path := range flags.Args() {
+		err := accesslog.ReadFile(path, func(record accesslog.Record) error {
+			if *appID != "" && record.AppID != *appID {
+				return nil
+			}
+			tenant, ok := config.Conf.Services.RailgunCDN.Tenants[record.AppID]
+			if !ok || tenant.SiteID == "" {
+				skipped++
+				return nil
+			}
+			// Apply the privacy settings of the tenant again, in case they became stricter since the record was written
+			event, ok := analytics.ApplyPrivacy(record.Event, tenant.Privacy, record.EventName, record.DoNotTrack)
+			if !ok {
+				skipped++
+				return nil
+			}
+			event.SiteID = tenant.SiteID
+			batch = append(batch, analytics.MatomoEvent(event))
+			if len(batch) >= *batchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "replay-accesslog: %v\n", err)
+			_, _ = fmt.Fprintf(os.Stderr, "replay-accesslog: %d events sent before the error\n", sent)
+			return 1
+		}
+	}
+
+	fmt.Printf("%d events sent, %d skipped\n", sent, skipped)
+	return 0
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"github.com/cloudwego/hertz/pkg/app/server"
+
+	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/metrics"
+)
+
+// adminRouteRegister registers the health and metrics routes.
+func adminRouteRegister(r *server.Hertz) {
+	r.NoMethod(common.InvalidAPIPathHandler)
+
+	common_ := r.Group("/common/v1")
+	common_.GET("/ping", common.Ping)
+	common_.GET("/health/live", common.Live)
+	common_.GET("/health/ready", common.Ready)
+
+	r.GET("/metrics", metrics.Handler)
+}
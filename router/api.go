@@ -2,24 +2,202 @@ package router
 
 import (
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
 
 	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/openapi"
 	"github.com/tundrawork/stargate/app/railgun_cdn"
+	"github.com/tundrawork/stargate/app/railgun_cdn/api"
 )
 
-// apiRouteRegister registers all API routes.
+// apiRouteRegister registers the tenant API routes, documenting them in apiDocument.
 func apiRouteRegister(r *server.Hertz) {
 	r.NoMethod(common.InvalidAPIPathHandler)
 
-	common_ := r.Group("/common/v1")
-	common_.GET("/ping", common.Ping)
-
 	railgun_ := r.Group("/railgun/v1")
-	railgun_.GET("/bucket", railgun_cdn.GetBucket)
-	railgun_.GET("/object", railgun_cdn.HeadObject)
-	railgun_.PUT("/object", railgun_cdn.PutObject)
-	railgun_.DELETE("/object", railgun_cdn.DeleteObject)
-	railgun_.GET("/url", railgun_cdn.GetURL)
-	railgun_.GET("/gateway", railgun_cdn.ClientGateway)
-	railgun_.HEAD("/gateway", railgun_cdn.ClientGateway)
+	handle(railgun_, consts.MethodGet, "/bucket", railgun_cdn.GetBucket, openapi.Operation{
+		OperationID: "getBucket",
+		Summary:     "List all objects in the tenant's bucket",
+		Parameters: tenantHeaders(
+			header("X-Prefix", false, `Only the objects whose path starts with this prefix are listed, e.g. "/img/". Must start with a slash.`),
+			header("X-With-Metadata", false, `If set to "true", the full metadata of every object is returned, including headers and user metadata. This is slower for large buckets.`),
+		),
+		Responses: responses(api.ListObjectsResponse{}),
+	})
+	handle(railgun_, consts.MethodGet, "/object", railgun_cdn.HeadObject, openapi.Operation{
+		OperationID: "headObject",
+		Summary:     "Get the metadata of an object",
+		Parameters:  tenantHeaders(objectPathHeader),
+		Responses:   responses(api.HeadObjectResponse{}),
+	})
+	handle(railgun_, consts.MethodPut, "/object", railgun_cdn.PutObject, openapi.Operation{
+		OperationID: "putObject",
+		Summary:     "Upload a new object",
+		Description: "The checksums of the body are verified against the provided checksum headers and the storage. " +
+			"On mismatch, a CHECKSUM_MISMATCH error is returned and the previous version of the object, if any, is kept.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			header("Content-Type", false, `The MIME type of the object, "application/octet-stream" if not present.`),
+			header("X-TTL", false, "The object's lifespan in seconds. The object will be deleted after this duration."),
+			header("Cache-Control", false, "Stored with the object and returned to clients."),
+			header("Content-Disposition", false, "Stored with the object and returned to clients, e.g. to set the download filename."),
+			header("Content-Encoding", false, `Stored with the object and returned to clients, e.g. "gzip" for pre-compressed objects.`),
+			header("Content-Language", false, "Stored with the object and returned to clients."),
+			metaHeader,
+			header("Content-MD5", false, "Base64 encoded MD5 digest of the body."),
+			header("X-Checksum-SHA256", false, "Hex encoded SHA-256 digest of the body."),
+			header("X-Checksum-CRC64", false, "CRC-64/ECMA checksum of the body, in decimal."),
+		),
+		RequestBody: &openapi.RequestBody{
+			Description: "The byte stream of the object, an empty body creates an empty object.",
+			Content: map[string]openapi.MediaType{
+				"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			},
+		},
+		Responses: responses(api.PutObjectResponse{}),
+	})
+	handle(railgun_, consts.MethodPatch, "/object", railgun_cdn.UpdateObject, openapi.Operation{
+		OperationID: "updateObject",
+		Summary:     "Update the headers and user metadata of an object without re-uploading it",
+		Description: "Headers that are not present keep their current values, user metadata is merged into the existing one. " +
+			"If the object is written at the same time, the update fails with a CONFLICT error and may be retried.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			header("Content-Type", false, "The MIME type of the object."),
+			header("Cache-Control", false, "Stored with the object and returned to clients."),
+			header("Content-Disposition", false, "Stored with the object and returned to clients."),
+			header("Content-Encoding", false, "Stored with the object and returned to clients."),
+			header("Content-Language", false, "Stored with the object and returned to clients."),
+			metaHeader,
+		),
+		Responses: responses(api.PutObjectResponse{}),
+	})
+	handle(railgun_, consts.MethodDelete, "/object", railgun_cdn.DeleteObject, openapi.Operation{
+		OperationID: "deleteObject",
+		Summary:     "Delete an object",
+		Parameters:  tenantHeaders(objectPathHeader),
+		Responses:   responses(nil),
+	})
+	handle(railgun_, consts.MethodPost, "/object/uploads", railgun_cdn.InitiateUpload, openapi.Operation{
+		OperationID: "initiateUpload",
+		Summary:     "Start a multipart upload, for objects too large to be uploaded in a single request",
+		Description: "The object is created once the upload is completed. Incomplete uploads must be aborted to free their parts.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			header("Content-Type", false, `The MIME type of the object, "application/octet-stream" if not present.`),
+			header("X-TTL", false, "The object's lifespan in seconds. The object will be deleted after this duration."),
+			header("Cache-Control", false, "Stored with the object and returned to clients."),
+			header("Content-Disposition", false, "Stored with the object and returned to clients, e.g. to set the download filename."),
+			header("Content-Encoding", false, `Stored with the object and returned to clients, e.g. "gzip" for pre-compressed objects.`),
+			header("Content-Language", false, "Stored with the object and returned to clients."),
+			metaHeader,
+		),
+		Responses: responses(api.InitiateUploadResponse{}),
+	})
+	handle(railgun_, consts.MethodPut, "/object/uploads/part", railgun_cdn.UploadPart, openapi.Operation{
+		OperationID: "uploadPart",
+		Summary:     "Upload a part of a multipart upload",
+		Description: "Parts are at least 1 MiB, except the last one, and are replaced when uploaded again with the same number. " +
+			"The checksums of the part are verified against the provided checksum headers and the storage.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			uploadIDHeader,
+			header("X-Part-Number", true, "Number of the part, from 1 to 10000. Parts are assembled in ascending order of numbers."),
+			header("Content-Length", true, "Size of the part in bytes."),
+			header("Content-MD5", false, "Base64 encoded MD5 digest of the part."),
+			header("X-Checksum-SHA256", false, "Hex encoded SHA-256 digest of the part."),
+			header("X-Checksum-CRC64", false, "CRC-64/ECMA checksum of the part, in decimal."),
+		),
+		RequestBody: &openapi.RequestBody{
+			Description: "The byte stream of the part.",
+			Required:    true,
+			Content: map[string]openapi.MediaType{
+				"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			},
+		},
+		Responses: responses(api.UploadPartResponse{}),
+	})
+	handle(railgun_, consts.MethodPost, "/object/uploads/complete", railgun_cdn.CompleteUpload, openapi.Operation{
+		OperationID: "completeUpload",
+		Summary:     "Complete a multipart upload, assembling its parts into the object",
+		Description: "On CRC-64 mismatch, the object is deleted and a CHECKSUM_MISMATCH error is returned.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			uploadIDHeader,
+			header("X-Checksum-CRC64", false, "CRC-64/ECMA checksum of the whole object, in decimal."),
+		),
+		RequestBody: jsonBody(railgun_cdn.CompleteUploadRequest{}, "The parts of the object, in ascending order of part numbers."),
+		Responses:   responses(api.PutObjectResponse{}),
+	})
+	handle(railgun_, consts.MethodDelete, "/object/uploads", railgun_cdn.AbortUpload, openapi.Operation{
+		OperationID: "abortUpload",
+		Summary:     "Abort a multipart upload, deleting its uploaded parts",
+		Parameters:  tenantHeaders(objectPathHeader, uploadIDHeader),
+		Responses:   responses(nil),
+	})
+	handle(railgun_, consts.MethodGet, "/url", railgun_cdn.GetURL, openapi.Operation{
+		OperationID: "getURL",
+		Summary:     "Get the signed URL of an object",
+		Description: "The existence of the object is not checked, the URL of a missing object leads to a 404 error.",
+		Parameters: tenantHeaders(
+			objectPathHeader,
+			header("X-TTL", true, "The URL's lifespan in seconds. Must be a positive value, at most the configured maximum (7 days by default)."),
+		),
+		Responses: responses(railgun_cdn.GetURLResponse{}),
+	})
+	handle(railgun_, consts.MethodPost, "/urls", railgun_cdn.GetURLs, openapi.Operation{
+		OperationID: "getURLs",
+		Summary:     "Get the signed URLs of multiple objects at once, at most 1000 per request",
+		Description: "Errors are reported per object path, a failed object does not fail the whole batch. " +
+			"An object path listed twice fails the whole batch, as the response is keyed by object path.",
+		Parameters: tenantHeaders(
+			header("X-TTL", false, "The default lifespan in seconds of the URLs of objects without a ttl."),
+		),
+		RequestBody: jsonBody(railgun_cdn.GetURLsRequest{}, "The objects to sign."),
+		Responses:   responses(railgun_cdn.GetURLsResponse{}),
+	})
+	handle(railgun_, consts.MethodPost, "/revoke", railgun_cdn.Revoke, openapi.Operation{
+		OperationID: "revoke",
+		Summary:     "Revoke signed URLs before they expire, by URL, by object path or by issue time",
+		Description: "Revoked URLs are rejected by the gateway with a REVOKED error. " +
+			"Revocation is enforced by the gateway only, it does not invalidate the underlying CDN URL.",
+		Parameters:  tenantHeaders(),
+		RequestBody: jsonBody(railgun_cdn.RevokeRequest{}, "At least one of url, objectPath and before, a Unix time in seconds not in the future."),
+		Responses:   responses(nil),
+	})
+	handle(railgun_, consts.MethodPost, "/cache/purge", railgun_cdn.PurgeCache, openapi.Operation{
+		OperationID: "purgeCache",
+		Summary:     "Purge the CDN cache of objects and directories",
+		Description: "Tenants with auto purge enabled have the cache of an object purged after it is uploaded, updated or deleted.",
+		Parameters:  tenantHeaders(),
+		RequestBody: jsonBody(railgun_cdn.CacheRequest{}, "At least one of objectPaths and directories. Directories must start and end with a \"/\"."),
+		Responses:   responses(railgun_cdn.CacheResponse{}),
+	})
+	handle(railgun_, consts.MethodPost, "/cache/prefetch", railgun_cdn.PrefetchCache, openapi.Operation{
+		OperationID: "prefetchCache",
+		Summary:     "Prefetch objects to the CDN edge nodes",
+		Parameters:  tenantHeaders(),
+		RequestBody: jsonBody(railgun_cdn.CacheRequest{}, "The objectPaths to prefetch, directories are not supported."),
+		Responses:   responses(railgun_cdn.CacheResponse{}),
+	})
+	gateway := openapi.Operation{
+		OperationID: "clientGateway",
+		Summary:     "Redirect a signed URL to the object on the CDN",
+		Description: "The URLs returned by getURL and getURLs point here. Revoked URLs are rejected with a REVOKED error.",
+		Parameters: []openapi.Parameter{
+			query("a", true, "Tenant's AppID"),
+			query("o", true, "Full path of the object"),
+			query("s", true, "Signature of the URL"),
+			query("t", true, "Expiry timestamp of the URL"),
+			query("i", false, "Issue time of the URL"),
+			query("v", false, "Signature of the issue time"),
+		},
+		Responses: map[string]openapi.Response{
+			"302":     {Description: "Redirect to the object on the CDN, not to be cached"},
+			"default": errorResponse,
+		},
+	}
+	handle(railgun_, consts.MethodGet, "/gateway", railgun_cdn.ClientGateway, gateway)
+	gateway.OperationID = "clientGatewayHead"
+	handle(railgun_, consts.MethodHead, "/gateway", railgun_cdn.ClientGateway, gateway)
 }
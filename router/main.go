@@ -1,9 +1,36 @@
 package router
 
-import "github.com/cloudwego/hertz/pkg/app/server"
+import (
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
 
-// Register registers all routes.
+	"github.com/tundrawork/stargate/app/common/shutdown"
+)
+
+// UploadRoutes are the routes starting new uploads, rejected while the server drains.
+var UploadRoutes = []shutdown.Route{
+	{Method: consts.MethodPut, Path: "/railgun/v1/object"},
+	{Method: consts.MethodPost, Path: "/railgun/v1/object/uploads"},
+}
+
+// Register registers the tenant routes, served on the public listener.
 func Register(r *server.Hertz) {
 	apiRouteRegister(r)
+}
+
+// RegisterServers registers the tenant routes on the public server, and the operational routes on the admin server,
+// or on the public server if admin is nil.
+func RegisterServers(public *server.Hertz, admin *server.Hertz) {
+	Register(public)
+	if admin == nil {
+		admin = public
+	}
+	RegisterAdmin(admin)
+}
+
+// RegisterAdmin registers the operational routes: health, metrics and docs.
+// They are served on the admin listener if one is configured, or on the public listener otherwise.
+func RegisterAdmin(r *server.Hertz) {
+	adminRouteRegister(r)
 	webRouteRegister(r)
 }
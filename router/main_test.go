@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+// TestUploadRoutesRegistered checks that the routes rejected while draining are registered, so that renaming one
+// does not silently let new uploads start during shutdown.
+func TestUploadRoutesRegistered(t *testing.T) {
+	h := server.New()
+	Register(h)
+
+	for _, upload := range UploadRoutes {
+		found := false
+		for _, route := range h.Routes() {
+			found = found || (route.Method == upload.Method && route.Path == upload.Path)
+		}
+		if !found {
+			t.Errorf("upload route %s %s is not registered", upload.Method, upload.Path)
+		}
+	}
+}
+
+// hasRoute reports whether a server has a route.
+func hasRoute(h *server.Hertz, method, path string) bool {
+	for _, route := range h.Routes() {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// TestRegisterServers checks that the operational routes are served only by the admin server when there is one,
+// and by the public server otherwise.
+func TestRegisterServers(t *testing.T) {
+	tenantRoute := UploadRoutes[0]
+	adminRoutes := []string{"/common/v1/health/live", "/common/v1/health/ready", "/metrics", "/docs/:file"}
+
+	public, admin := server.New(), server.New()
+	RegisterServers(public, admin)
+	if !hasRoute(public, tenantRoute.Method, tenantRoute.Path) || hasRoute(admin, tenantRoute.Method, tenantRoute.Path) {
+		t.Errorf("tenant route %s %s not served only by the public server", tenantRoute.Method, tenantRoute.Path)
+	}
+	for _, path := range adminRoutes {
+		if hasRoute(public, http.MethodGet, path) || !hasRoute(admin, http.MethodGet, path) {
+			t.Errorf("operational route %s not served only by the admin server", path)
+		}
+	}
+	if w := ut.PerformRequest(admin.Engine, http.MethodGet, "/common/v1/health/live", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /common/v1/health/live on the admin server = %d", w.Code)
+	}
+	if w := ut.PerformRequest(public.Engine, http.MethodGet, "/common/v1/health/live", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET /common/v1/health/live on the public server = %d, want 404", w.Code)
+	}
+
+	public = server.New()
+	RegisterServers(public, nil)
+	if !hasRoute(public, tenantRoute.Method, tenantRoute.Path) {
+		t.Errorf("tenant route %s %s not served without admin server", tenantRoute.Method, tenantRoute.Path)
+	}
+	for _, path := range adminRoutes {
+		if !hasRoute(public, http.MethodGet, path) {
+			t.Errorf("operational route %s not served by the public server without admin server", path)
+		}
+	}
+	if w := ut.PerformRequest(public.Engine, http.MethodGet, "/common/v1/health/live", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /common/v1/health/live on the public server without admin server = %d", w.Code)
+	}
+}
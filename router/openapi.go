@@ -0,0 +1,107 @@
+package router
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"github.com/tundrawork/stargate/app/common"
+	"github.com/tundrawork/stargate/app/common/apierror"
+	"github.com/tundrawork/stargate/app/common/openapi"
+)
+
+// apiDocument is the OpenAPI document of the tenant API, filled by the route registrations of apiRouteRegister.
+var apiDocument = openapi.New(openapi.Info{
+	Title:   "Stargate Railgun CDN",
+	Version: "v1",
+	Description: "A simple CDN as a Service implementation with multiple tenants support. " +
+		"Errors are returned with their HTTP status and a stable machine-readable code.",
+})
+
+// handle registers a route and documents its operation.
+func handle(g *route.RouterGroup, method, path string, handler app.HandlerFunc, op openapi.Operation) {
+	g.Handle(method, path, handler)
+	fullPath := strings.TrimSuffix(g.BasePath(), "/") + path
+	apiDocument.Add(method, openapi.PathFromRoute(fullPath), op)
+}
+
+// OpenAPIHandler serves the OpenAPI document of the tenant API.
+func OpenAPIHandler(_ context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, apiDocument)
+}
+
+func header(name string, required bool, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "header",
+		Required:    required,
+		Description: description,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+func query(name string, required bool, description string) openapi.Parameter {
+	parameter := header(name, required, description)
+	parameter.In = "query"
+	return parameter
+}
+
+// tenantHeaders returns the authentication headers of the tenant API, followed by the given headers.
+func tenantHeaders(headers ...openapi.Parameter) []openapi.Parameter {
+	return append([]openapi.Parameter{
+		header("X-App-Id", true, "Tenant's AppID"),
+		header("X-App-Key", true, "Tenant's AppKey, may be omitted when the request presents a client certificate of the tenant"),
+	}, headers...)
+}
+
+var (
+	objectPathHeader = header("X-Object-Path", true, `Full path of the object. Must start with a "/" and not end with a "/".`)
+	metaHeader       = header("X-Meta-*", false, `User metadata. The key is the part after "X-Meta-" in lower case.`)
+	uploadIDHeader   = header("X-Upload-Id", true, "ID of the multipart upload, as returned by initiateUpload.")
+)
+
+// jsonBody documents a required JSON request body of the type of v.
+func jsonBody(v any, description string) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Description: description,
+		Required:    true,
+		Content: map[string]openapi.MediaType{
+			"application/json": {Schema: openapi.SchemaOf(v)},
+		},
+	}
+}
+
+// responses documents a success response wrapping data of the type of v in the API response envelope,
+// and the error response.
+func responses(v any) map[string]openapi.Response {
+	success := openapi.SchemaOf(common.APIResponse{})
+	success.Properties["data"] = openapi.SchemaOf(v)
+	delete(success.Properties, "error")
+	return map[string]openapi.Response{
+		"200": {
+			Description: "Success",
+			Content: map[string]openapi.MediaType{
+				"application/json": {Schema: success},
+			},
+		},
+		"default": errorResponse,
+	}
+}
+
+var errorResponse = func() openapi.Response {
+	schema := openapi.SchemaOf(common.APIResponse{})
+	detail := schema.Properties["error"]
+	detail.Nullable = false
+	for _, code := range apierror.Codes() {
+		detail.Properties["code"].Enum = append(detail.Properties["code"].Enum, string(code))
+	}
+	return openapi.Response{
+		Description: "Error, see error.code",
+		Content: map[string]openapi.MediaType{
+			"application/json": {Schema: schema},
+		},
+	}
+}()
@@ -0,0 +1,31 @@
+package router
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+
+	"github.com/tundrawork/stargate/app/common/openapi"
+)
+
+// TestAPIRoutesDocumented checks that the tenant API routes and the OpenAPI document match both ways, so that a route
+// registered without handle or a stale operation fails the build instead of shipping.
+func TestAPIRoutesDocumented(t *testing.T) {
+	h := server.New()
+	apiRouteRegister(h)
+
+	var registered []string
+	for _, route := range h.Routes() {
+		path := openapi.PathFromRoute(route.Path)
+		if !apiDocument.Has(route.Method, path) {
+			t.Errorf("route %s %s is not documented, register it with handle", route.Method, route.Path)
+		}
+		registered = append(registered, route.Method+" "+path)
+	}
+	for _, route := range apiDocument.Routes() {
+		if !slices.Contains(registered, route) {
+			t.Errorf("operation %s is documented but not registered", route)
+		}
+	}
+}
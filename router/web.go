@@ -6,8 +6,10 @@ import (
 	"github.com/tundrawork/stargate/app/common"
 )
 
-// webRouteRegister registers all API routes.
+// webRouteRegister registers the documentation routes.
 func webRouteRegister(r *server.Hertz) {
 	docs_ := r.Group("/docs")
+	docs_.GET("/openapi.json", OpenAPIHandler)
+	docs_.GET("/swagger-ui/:asset", common.SwaggerUIHandler)
 	docs_.GET("/:file", common.DocsHandler)
 }
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+// TestSwaggerUIServedLocally checks that the OpenAPI documentation loads the vendored Swagger UI, not a CDN copy.
+func TestSwaggerUIServedLocally(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	page, err := os.ReadFile("docs/openapi.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "https://") || !strings.Contains(string(page), `src="swagger-ui/swagger-ui-bundle.js"`) {
+		t.Errorf("the documentation page does not load the vendored Swagger UI:\n%s", page)
+	}
+
+	h := server.New()
+	webRouteRegister(h)
+	for asset, contentType := range map[string]string{
+		"swagger-ui-bundle.js": "javascript",
+		"swagger-ui.css":       "text/css",
+	} {
+		w := ut.PerformRequest(h.Engine, http.MethodGet, "/docs/swagger-ui/"+asset, nil)
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("GET %s status = %d, %d bytes", asset, w.Code, w.Body.Len())
+		}
+		if got := w.Header().Get("Content-Type"); !strings.Contains(got, contentType) {
+			t.Errorf("GET %s Content-Type = %q, want %s", asset, got, contentType)
+		}
+	}
+	if w := ut.PerformRequest(h.Engine, http.MethodGet, "/docs/swagger-ui/LICENSE", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET LICENSE status = %d, want only the Swagger UI assets to be served", w.Code)
+	}
+}